@@ -0,0 +1,29 @@
+// Package v1alpha1 contains the API types of the kubenurse.postfinance.ch group.
+// +kubebuilder:object:generate=true
+// +groupName=kubenurse.postfinance.ch
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+//nolint:gochecknoglobals // standard API registration
+var (
+	// GroupVersion is the group version used to register these objects.
+	GroupVersion = schema.GroupVersion{Group: "kubenurse.postfinance.ch", Version: "v1alpha1"}
+
+	// SchemeBuilder is used to add the types of this group version to a scheme.
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+
+	// AddToScheme adds the types of this group version to the given scheme.
+	AddToScheme = SchemeBuilder.AddToScheme
+)
+
+func addKnownTypes(s *runtime.Scheme) error {
+	s.AddKnownTypes(GroupVersion, &KubenurseCheck{}, &KubenurseCheckList{})
+	metav1.AddToGroupVersion(s, GroupVersion)
+
+	return nil
+}
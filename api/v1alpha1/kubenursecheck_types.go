@@ -0,0 +1,79 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KubenurseCheckSpec defines an endpoint which is checked by the kubenurses.
+type KubenurseCheckSpec struct {
+	// URL is the http or https endpoint to check, or a tcp://host:port or
+	// tls://host:port url for a TCP connect check, optionally followed by a
+	// TLS handshake.
+	// +kubebuilder:validation:Pattern=`^(https?|tcp|tls)://.+`
+	URL string `json:"url"`
+
+	// Method is the http method of the request, defaults to GET.
+	// +optional
+	Method string `json:"method,omitempty"`
+
+	// ExpectedStatus is the http status code of a successful check, defaults to 200.
+	// +optional
+	ExpectedStatus int `json:"expectedStatus,omitempty"`
+
+	// Interval is the minimum duration between two checks, the check runs at
+	// most once per kubenurse check interval. Defaults to every check interval.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// Timeout of the request, defaults to the http client timeout.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// NodeSelector restricts the check to the kubenurses running on nodes with
+	// matching labels. Defaults to all nodes.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+}
+
+// KubenurseCheckStatus reports the results of the check.
+type KubenurseCheckStatus struct {
+	// Nodes contains the aggregated results, reported by the kubenurse of
+	// every node running the check.
+	// +optional
+	Nodes map[string]NodeCheckStatus `json:"nodes,omitempty"`
+}
+
+// NodeCheckStatus aggregates the results of a check on a node since the
+// kubenurse of this node started.
+type NodeCheckStatus struct {
+	Passed int64 `json:"passed"`
+	Failed int64 `json:"failed"`
+
+	// LastResult is the result of the last check, "ok" or the error.
+	// +optional
+	LastResult string `json:"lastResult,omitempty"`
+
+	// LastCheckTime is the time of the last check.
+	// +optional
+	LastCheckTime metav1.Time `json:"lastCheckTime,omitempty"`
+}
+
+// KubenurseCheck is an additional check performed by the kubenurses.
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.spec.url`
+type KubenurseCheck struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KubenurseCheckSpec   `json:"spec,omitempty"`
+	Status KubenurseCheckStatus `json:"status,omitempty"`
+}
+
+// KubenurseCheckList contains a list of KubenurseCheck.
+// +kubebuilder:object:root=true
+type KubenurseCheckList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KubenurseCheck `json:"items"`
+}
@@ -50,6 +50,27 @@ app.kubernetes.io/name: {{ include "kubenurse.name" . }}
 app.kubernetes.io/instance: {{ .Release.Name }}
 {{- end -}}
 
+{{/*
+Labels of the aggregator, its pods must not match the neighbour filter of the
+kubenurses
+*/}}
+{{- define "kubenurse.aggregatorLabels" -}}
+helm.sh/chart: {{ include "kubenurse.chart" . }}
+{{ include "kubenurse.aggregatorSelectorLabels" . }}
+{{- if .Chart.AppVersion }}
+app.kubernetes.io/version: {{ .Chart.AppVersion | quote }}
+{{- end }}
+app.kubernetes.io/managed-by: {{ .Release.Service }}
+{{- end -}}
+
+{{/*
+Selector labels of the aggregator
+*/}}
+{{- define "kubenurse.aggregatorSelectorLabels" -}}
+app.kubernetes.io/name: {{ include "kubenurse.name" . }}-aggregator
+app.kubernetes.io/instance: {{ .Release.Name }}
+{{- end -}}
+
 {{/*
 Create the name of the service account to use
 */}}
@@ -60,3 +81,15 @@ Create the name of the service account to use
     {{ default "default" .Values.serviceAccount.name }}
 {{- end -}}
 {{- end -}}
+
+{{/*
+Discovery mode, from the discovery value or else from the config file, as the
+environment variables take precedence over the file
+*/}}
+{{- define "kubenurse.discovery" -}}
+{{- if not (kindIs "invalid" .Values.discovery) -}}
+{{ .Values.discovery }}
+{{- else -}}
+{{ dig "discovery" "mode" "pods" (.Values.config | default dict) }}
+{{- end -}}
+{{- end -}}
@@ -0,0 +1,950 @@
+// Package config contains the kubenurse configuration. It can be loaded from a
+// YAML or JSON file, and every setting can be overridden with the historical
+// KUBENURSE_* environment variables.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// FileEnv is the environment variable holding the path of the configuration file.
+const FileEnv = "KUBENURSE_CONFIG_FILE"
+
+// maxMTUSize is the largest payload returned by the /alwayshappy endpoint.
+const maxMTUSize = 64 * 1024
+
+// reservedCheckNames are the names of the built-in checks, which cannot be
+// used for extra checks.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var reservedCheckNames = map[string]bool{
+	"api_server_direct":   true,
+	"api_server_dns":      true,
+	"me_ingress":          true,
+	"me_service":          true,
+	"neighbourhood":       true,
+	"neighbourhood_state": true,
+	"dns_state":           true,
+	"kubelet":             true,
+	"kube_proxy":          true,
+}
+
+// reservedCheckPrefixes are the prefixes of the built-in checks which are
+// performed once per neighbour or server.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var reservedCheckPrefixes = []string{"path_", "host_path_", "dns_", "udp_", "mtu_", "kubelet_", "kube_proxy_"}
+
+//nolint:gochecknoglobals // read-only lookup table
+var validMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// Config is the complete kubenurse configuration.
+type Config struct {
+	// File is the path from which the configuration was loaded, it is empty
+	// when only the defaults and environment variables are used.
+	File string `json:"-"`
+
+	Server    Server    `json:"server"`
+	Checker   Checker   `json:"checker"`
+	Discovery Discovery `json:"discovery"`
+	Checks    Checks    `json:"checks"`
+	Analysis  Analysis  `json:"analysis"`
+	// Aggregator configures the kubenurse aggregate subcommand.
+	Aggregator Aggregator `json:"aggregator"`
+	Events     Events     `json:"events"`
+	// NodeCondition configures the condition which kubenurse publishes on its node.
+	NodeCondition NodeCondition `json:"nodeCondition"`
+	Metrics       Metrics       `json:"metrics"`
+	Tracing       Tracing       `json:"tracing"`
+	// Resource describes the kubenurse in the exported traces and metrics.
+	Resource Resource `json:"resource"`
+}
+
+// Server configures the kubenurse http/https server(s). Changes to this
+// section require a restart.
+type Server struct {
+	// KUBENURSE_USE_TLS
+	UseTLS bool `json:"useTLS"`
+	// KUBENURSE_CERT_FILE
+	CertFile string `json:"certFile"`
+	// KUBENURSE_CERT_KEY
+	CertKey string `json:"certKey"`
+	// KUBENURSE_SHUTDOWN_DURATION
+	ShutdownDuration metav1.Duration `json:"shutdownDuration"`
+	// ReloadInterval defines how often the configuration file is checked for changes.
+	ReloadInterval metav1.Duration `json:"reloadInterval"`
+}
+
+// Checker configures the periodic checker and the neighbourhood discovery.
+type Checker struct {
+	// KUBENURSE_CHECK_INTERVAL
+	Interval metav1.Duration `json:"interval"`
+	// KUBENURSE_ALLOW_UNSCHEDULABLE, changes require a restart.
+	AllowUnschedulable bool `json:"allowUnschedulable"`
+	// KUBENURSE_INGRESS_URL
+	IngressURL string `json:"ingressURL"`
+	// KUBENURSE_SERVICE_URL
+	ServiceURL string `json:"serviceURL"`
+	// KUBENURSE_NAMESPACE, changes require a restart.
+	Namespace string `json:"namespace"`
+	// KUBENURSE_NODE_NAME is the node the kubenurse runs on, set by the helm
+	// chart with the downward API. If it is empty, the node is found during
+	// the neighbours discovery, or is the hostname in the standalone mode.
+	NodeName string `json:"nodeName"`
+	// KUBENURSE_NEIGHBOUR_FILTER
+	NeighbourFilter string `json:"neighbourFilter"`
+	// KUBENURSE_NEIGHBOUR_LIMIT
+	NeighbourLimit int `json:"neighbourLimit"`
+	// KUBENURSE_NEIGHBOUR_SELECTION is the strategy used to select
+	// neighbourLimit neighbours, hash or zone.
+	NeighbourSelection string `json:"neighbourSelection"`
+	// NeighbourTopology configures the zone selection.
+	NeighbourTopology NeighbourTopology `json:"neighbourTopology"`
+	// KUBENURSE_NEIGHBOUR_ROTATION is the period after which the selected
+	// neighbours change, the selection is fixed if it is 0.
+	NeighbourRotation metav1.Duration `json:"neighbourRotation"`
+	// KUBERNETES_SERVICE_DNS
+	KubernetesServiceDNS string `json:"kubernetesServiceDNS"`
+	// KUBERNETES_SERVICE_HOST
+	KubernetesServiceHost string `json:"kubernetesServiceHost"`
+	// KUBERNETES_SERVICE_PORT
+	KubernetesServicePort string `json:"kubernetesServicePort"`
+	// KUBENURSE_HISTORY_DEPTH is the number of past results kept per check
+	// and returned by /history, the history is disabled if it is 0.
+	HistoryDepth int `json:"historyDepth"`
+}
+
+// Selection strategies of the neighbours.
+const (
+	// SelectionHash selects the closest neighbours by the hashes of their
+	// node names.
+	SelectionHash = "hash"
+	// SelectionZone guarantees a number of neighbours in the own zone, in
+	// every other zone and in every other region.
+	SelectionZone = "zone"
+)
+
+// NeighbourTopology are the minimum numbers of neighbours selected by the
+// zone selection, from the topology.kubernetes.io labels of the nodes.
+type NeighbourTopology struct {
+	// KUBENURSE_NEIGHBOURS_SAME_ZONE
+	SameZone int `json:"sameZone"`
+	// KUBENURSE_NEIGHBOURS_PER_ZONE is the number of neighbours in every
+	// other zone of the region.
+	PerZone int `json:"perZone"`
+	// KUBENURSE_NEIGHBOURS_PER_REGION is the number of neighbours in every
+	// other region.
+	PerRegion int `json:"perRegion"`
+}
+
+// Discovery modes of the neighbours.
+const (
+	// DiscoveryPods lists the kubenurse pods with the Kubernetes API.
+	DiscoveryPods = "pods"
+	// DiscoveryEndpointSlices lists the EndpointSlices of the kubenurse service.
+	DiscoveryEndpointSlices = "endpointSlices"
+	// DiscoveryStatic uses the peers of the configuration.
+	DiscoveryStatic = "static"
+	// DiscoveryFile reads the peers from a file.
+	DiscoveryFile = "file"
+	// DiscoveryDNS resolves the peers from DNS records.
+	DiscoveryDNS = "dns"
+)
+
+// Discovery configures how the neighbours are found. The static, file and dns
+// modes don't use the Kubernetes API, and enable the standalone mode which
+// monitors hosts outside of Kubernetes. Changes to this section require a
+// restart.
+type Discovery struct {
+	// KUBENURSE_DISCOVERY is pods, endpointSlices, static, file or dns.
+	Mode string `json:"mode"`
+	// KUBENURSE_DISCOVERY_SERVICE is the kubenurse service in
+	// checker.namespace, whose EndpointSlices are used by the endpointSlices
+	// mode.
+	Service string `json:"service"`
+	// KUBENURSE_PEERS, a comma separated list of host[:port] for the static
+	// mode. The port defaults to the one of the kubenurse listener.
+	Peers []string `json:"peers"`
+	// KUBENURSE_PEERS_FILE holds a host[:port] per line for the file mode,
+	// it is read on every check interval.
+	PeersFile string `json:"peersFile"`
+	// KUBENURSE_PEERS_DNS is the name resolved by the dns mode, as SRV
+	// records if it starts with an underscore, else as A and AAAA records.
+	PeersDNS string `json:"peersDNS"`
+}
+
+// Standalone reports whether the neighbours are found without the
+// Kubernetes API.
+func (d *Discovery) Standalone() bool {
+	return d.Mode == DiscoveryStatic || d.Mode == DiscoveryFile || d.Mode == DiscoveryDNS
+}
+
+// Checks enables or disables the built-in checks and defines the extra ones.
+type Checks struct {
+	// KUBENURSE_CHECK_API_SERVER_DIRECT
+	APIServerDirect bool `json:"apiServerDirect"`
+	// KUBENURSE_CHECK_API_SERVER_DNS
+	APIServerDNS bool `json:"apiServerDNS"`
+	// KUBENURSE_CHECK_ME_INGRESS
+	MeIngress bool `json:"meIngress"`
+	// KUBENURSE_CHECK_ME_SERVICE
+	MeService bool `json:"meService"`
+	// KUBENURSE_CHECK_NEIGHBOURHOOD
+	Neighbourhood bool `json:"neighbourhood"`
+	// KUBENURSE_EXTRA_CHECKS
+	Extra []ExtraCheck `json:"extra"`
+	// KUBENURSE_CLUSTER_CHECKS enables the checks defined with KubenurseCheck resources.
+	ClusterChecks bool `json:"clusterChecks"`
+	DNS           DNS  `json:"dns"`
+	UDP           UDP  `json:"udp"`
+	// NeighbourhoodBurst configures the burst of probes of the neighbourhood check.
+	NeighbourhoodBurst Burst      `json:"neighbourhoodBurst"`
+	MTU                MTU        `json:"mtu"`
+	HostPath           HostPath   `json:"hostPath"`
+	NodeHealth         NodeHealth `json:"nodeHealth"`
+}
+
+// NodeHealth configures the checks of the /healthz endpoints of the node
+// components, on the node IP.
+type NodeHealth struct {
+	// KUBENURSE_CHECK_KUBELET enables the check of the local kubelet.
+	Kubelet bool `json:"kubelet"`
+	// KubeletPort is the port of the kubelet healthz endpoint, which must
+	// listen on the node IP (healthzBindAddress).
+	KubeletPort int `json:"kubeletPort"`
+	// KUBENURSE_CHECK_KUBE_PROXY enables the check of the local kube-proxy.
+	KubeProxy bool `json:"kubeProxy"`
+	// KubeProxyPort is the port of the kube-proxy healthz endpoint.
+	KubeProxyPort int `json:"kubeProxyPort"`
+	// KUBENURSE_CHECK_NEIGHBOUR_NODE_HEALTH also checks the enabled node
+	// components of every neighbour.
+	Neighbours bool `json:"neighbours"`
+}
+
+// HostPath configures the check of the neighbours through their node IP.
+type HostPath struct {
+	// KUBENURSE_CHECK_HOST_PATH, the check is only enabled with "true". The
+	// host listener is only started if the check is enabled at startup.
+	Enabled bool `json:"enabled"`
+	// KUBENURSE_HOST_PATH_PORT of the host listener, which must be reachable
+	// on the node IP, either with a hostPort or with the host network. Changes
+	// require a restart.
+	Port int `json:"port"`
+}
+
+// MTU configures the path MTU check between neighbours.
+type MTU struct {
+	// KUBENURSE_MTU_SIZES, a comma separated list of payload sizes in bytes
+	// sent to every neighbour. The check is disabled if it is empty.
+	Sizes []int `json:"sizes"`
+	// Timeout of the request of every size.
+	Timeout metav1.Duration `json:"timeout"`
+}
+
+// Burst configures a burst of probes sent to every neighbour, from which the
+// loss ratio, the round-trip time quantiles and the jitter are derived.
+type Burst struct {
+	// KUBENURSE_NEIGHBOUR_BURST, a single request is sent to every neighbour if
+	// it is 1.
+	Probes int `json:"probes"`
+	// Gap between two probes.
+	Gap metav1.Duration `json:"gap"`
+}
+
+// UDP configures the UDP echo check between neighbours.
+type UDP struct {
+	// KUBENURSE_CHECK_UDP, the UDP check is only enabled with "true". The
+	// echo listener is only started if the check is enabled at startup.
+	Enabled bool `json:"enabled"`
+	// KUBENURSE_UDP_PORT of the echo listener, changes require a restart.
+	Port int `json:"port"`
+	// Probes is the number of datagrams sent to every neighbour.
+	Probes int `json:"probes"`
+	// Timeout to wait for the echoed datagrams after the last one was sent.
+	Timeout metav1.Duration `json:"timeout"`
+}
+
+// DNS configures the check of every cluster DNS server.
+type DNS struct {
+	// KUBENURSE_CHECK_DNS, the DNS check is only enabled with "true".
+	Enabled bool `json:"enabled"`
+	// KUBENURSE_DNS_QUERIES, a comma separated list of <type>:<name>. Defaults
+	// to the A record of checker.kubernetesServiceDNS.
+	Queries []DNSQuery `json:"queries"`
+	// KUBENURSE_DNS_NAMESPACE of the cluster DNS service, changes require a restart.
+	Namespace string `json:"namespace"`
+	// KUBENURSE_DNS_SERVICE is the name of the cluster DNS service.
+	Service string `json:"service"`
+	// Timeout of every query.
+	Timeout metav1.Duration `json:"timeout"`
+}
+
+// DNSQuery is a name resolved against every DNS server.
+type DNSQuery struct {
+	// Name must be fully qualified, the search domains are not used.
+	Name string `json:"name"`
+	// Type is A, AAAA or SRV.
+	Type string `json:"type"`
+}
+
+// ExtraCheck is an additional endpoint that is checked on every interval.
+type ExtraCheck struct {
+	// Name is used as the type label of the metrics.
+	Name string `json:"name"`
+	// URL is an http(s) url, or a tcp://host:port or tls://host:port url
+	// for a TCP connect check, optionally followed by a TLS handshake.
+	URL string `json:"url"`
+	// Method defaults to GET.
+	Method string `json:"method,omitempty"`
+	// ExpectedStatus defaults to 200.
+	ExpectedStatus int `json:"expectedStatus,omitempty"`
+	// Interval is the minimum duration between two checks, defaults to every check interval.
+	Interval metav1.Duration `json:"interval,omitempty"`
+	// Timeout defaults to the http client timeout.
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// Analysis configures the analysis of the path checks of all the kubenurses.
+// Changes to this section require a restart.
+type Analysis struct {
+	// KUBENURSE_BLAME_ANALYSIS enables the periodic computation of the blame
+	// scores of every node, from the /mesh of all the kubenurses.
+	Blame bool `json:"blame"`
+	// KUBENURSE_PARTITION_DETECTION enables the periodic computation of the
+	// partitions of the nodes, from the /mesh of all the kubenurses.
+	Partitions bool `json:"partitions"`
+	// KUBENURSE_ANALYSIS_INTERVAL is the duration between two analyses.
+	Interval metav1.Duration `json:"interval"`
+}
+
+// Aggregator configures the aggregator, which collects the results of all
+// the kubenurses. Changes to this section require a restart.
+type Aggregator struct {
+	// KUBENURSE_AGGREGATOR_INTERVAL is the duration between two collections.
+	Interval metav1.Duration `json:"interval"`
+}
+
+// Events configures the reporting of the check transitions to Kubernetes.
+// Changes to this section require a restart.
+type Events struct {
+	// KUBENURSE_EVENTS enables the events on the nodes and the kubenurse pod
+	// when a check fails or recovers, and the KubenurseChecksHealthy
+	// condition of the kubenurse pod.
+	Enabled bool `json:"enabled"`
+}
+
+// NodeCondition configures the KubenurseNetworkHealthy condition of the
+// node, which summarizes the local checks.
+type NodeCondition struct {
+	// KUBENURSE_NODE_CONDITION enables the condition.
+	Enabled bool `json:"enabled"`
+	// FailureThreshold is the number of consecutive unhealthy runs after
+	// which the condition becomes false.
+	FailureThreshold int `json:"failureThreshold"`
+	// SuccessThreshold is the number of consecutive healthy runs after which
+	// the condition becomes true.
+	SuccessThreshold int `json:"successThreshold"`
+	// MaxPathFailureRatio is the ratio of failing neighbour paths above which
+	// the network is unhealthy.
+	MaxPathFailureRatio float64 `json:"maxPathFailureRatio"`
+}
+
+// Metrics configures the exposed metrics. Changes to this section require a restart.
+type Metrics struct {
+	// KUBENURSE_EXPOSE_METADATA
+	ExposeMetadata bool `json:"exposeMetadata"`
+	// KUBENURSE_VICTORIAMETRICS_HISTOGRAM
+	VictoriaMetricsHistogram bool `json:"victoriaMetricsHistogram"`
+	// KUBENURSE_HISTOGRAM_BUCKETS
+	HistogramBuckets []float64 `json:"histogramBuckets"`
+	// OTLP configures the push of the metrics to an OpenTelemetry collector.
+	OTLP OTLPMetrics `json:"otlp"`
+	// Push configures the push of the metrics to a Prometheus remote-write
+	// or VictoriaMetrics import endpoint.
+	Push Push `json:"push"`
+}
+
+// OTLPMetrics configures the periodic export of the metrics of /metrics with
+// OTLP/HTTP, for the clusters where the metrics cannot be scraped.
+type OTLPMetrics struct {
+	// KUBENURSE_OTLP_METRICS_ENDPOINT is the base url of an OTLP/HTTP
+	// collector, e.g. http://otel-collector:4318, to whose /v1/metrics path
+	// the metrics are sent. The export is disabled if it is empty.
+	Endpoint string `json:"endpoint"`
+	// KUBENURSE_OTLP_METRICS_INTERVAL is the duration between two exports.
+	Interval metav1.Duration `json:"interval"`
+}
+
+// Formats of the metrics push.
+const (
+	// PushRemoteWrite sends the metrics with the Prometheus remote-write
+	// protocol, e.g. to http://victoriametrics:8428/api/v1/write.
+	PushRemoteWrite = "remoteWrite"
+	// PushImport sends the metrics in the Prometheus text format, e.g. to
+	// http://victoriametrics:8428/api/v1/import/prometheus.
+	PushImport = "import"
+)
+
+// Push configures the periodic push of the metrics of /metrics, for the
+// clusters where the metrics cannot be scraped. A failed push is retried with
+// an exponential backoff until the next one is due.
+type Push struct {
+	// KUBENURSE_PUSH_URL is the url the metrics are sent to, the basic auth
+	// credentials may be part of it. The push is disabled if it is empty.
+	URL string `json:"url"`
+	// KUBENURSE_PUSH_FORMAT is either remoteWrite or import.
+	Format string `json:"format"`
+	// KUBENURSE_PUSH_INTERVAL is the duration between two pushes.
+	Interval metav1.Duration `json:"interval"`
+	// KUBENURSE_PUSH_EXTRA_LABELS, e.g. cluster=edge-1,env=prod, are added
+	// to every pushed series which doesn't have them yet, like the node and
+	// pod labels which are always added.
+	ExtraLabels map[string]string `json:"extraLabels"`
+}
+
+// Tracing configures the export of a trace of every run of the checks.
+// Changes to this section require a restart.
+type Tracing struct {
+	// KUBENURSE_TRACING_ENDPOINT is the base url of an OTLP/HTTP collector,
+	// e.g. http://otel-collector:4318, to whose /v1/traces path the traces
+	// are sent. The tracing is disabled if it is empty.
+	Endpoint string `json:"endpoint"`
+}
+
+// Resource holds the attributes of the kubenurse in the exported traces and
+// metrics, in addition to its pod, namespace and node. Changes to this
+// section require a restart.
+type Resource struct {
+	// KUBENURSE_CLUSTER_NAME is the k8s.cluster.name attribute, it is
+	// omitted if empty.
+	ClusterName string `json:"clusterName"`
+	// KUBENURSE_POD_NAME is the k8s.pod.name attribute and the pod label of
+	// the pushed metrics, set by the helm chart with the downward API. It
+	// defaults to the hostname.
+	PodName string `json:"podName"`
+}
+
+// Default returns the configuration used when nothing is configured.
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			ShutdownDuration: metav1.Duration{Duration: 5 * time.Second},
+			ReloadInterval:   metav1.Duration{Duration: 10 * time.Second},
+		},
+		Checker: Checker{
+			Interval:           metav1.Duration{Duration: 5 * time.Second},
+			NeighbourLimit:     10,
+			NeighbourSelection: SelectionHash,
+			NeighbourTopology: NeighbourTopology{
+				SameZone:  1,
+				PerZone:   1,
+				PerRegion: 1,
+			},
+			KubernetesServiceDNS: "kubernetes.default.svc.cluster.local",
+			HistoryDepth:         120,
+		},
+		Discovery: Discovery{
+			Mode: DiscoveryPods,
+		},
+		Checks: Checks{
+			APIServerDirect: true,
+			APIServerDNS:    true,
+			MeIngress:       true,
+			MeService:       true,
+			Neighbourhood:   true,
+			DNS: DNS{
+				Namespace: "kube-system",
+				Service:   "kube-dns",
+				Timeout:   metav1.Duration{Duration: 2 * time.Second},
+			},
+			UDP: UDP{
+				Port:    8081,
+				Probes:  10,
+				Timeout: metav1.Duration{Duration: time.Second},
+			},
+			NeighbourhoodBurst: Burst{
+				Probes: 1,
+				Gap:    metav1.Duration{Duration: 20 * time.Millisecond},
+			},
+			MTU: MTU{
+				Timeout: metav1.Duration{Duration: 2 * time.Second},
+			},
+			HostPath: HostPath{
+				Port: 8082,
+			},
+			NodeHealth: NodeHealth{
+				KubeletPort:   10248,
+				KubeProxyPort: 10256,
+			},
+		},
+		Analysis: Analysis{
+			Interval: metav1.Duration{Duration: time.Minute},
+		},
+		Aggregator: Aggregator{
+			Interval: metav1.Duration{Duration: 30 * time.Second},
+		},
+		NodeCondition: NodeCondition{
+			FailureThreshold:    3,
+			SuccessThreshold:    3,
+			MaxPathFailureRatio: 0.5,
+		},
+		Metrics: Metrics{
+			OTLP: OTLPMetrics{
+				Interval: metav1.Duration{Duration: 30 * time.Second},
+			},
+			Push: Push{
+				Format:   PushRemoteWrite,
+				Interval: metav1.Duration{Duration: 30 * time.Second},
+			},
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the file at path (if not
+// empty) and the environment variables, in that order of precedence, and
+// validates the result.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		b, err := os.ReadFile(path) //nolint:gosec // Intentionally included by the user.
+		if err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+
+		if err := yaml.UnmarshalStrict(b, cfg); err != nil {
+			return nil, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+
+		cfg.File = path
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// invalidFunc records that field has an invalid value.
+type invalidFunc func(field, format string, args ...any)
+
+// Validate checks the configuration and returns all the problems found,
+// prefixed with the path of the offending field.
+func (c *Config) Validate() error {
+	var errs []error
+
+	invalid := func(field, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Server.ShutdownDuration.Duration < 0 {
+		invalid("server.shutdownDuration", "must not be negative, got %s", c.Server.ShutdownDuration.Duration)
+	}
+
+	if c.Server.ReloadInterval.Duration <= 0 {
+		invalid("server.reloadInterval", "must be greater than zero, got %s", c.Server.ReloadInterval.Duration)
+	}
+
+	if c.Server.UseTLS && (c.Server.CertFile == "" || c.Server.CertKey == "") {
+		invalid("server", "certFile and certKey are required when useTLS is enabled")
+	}
+
+	if c.Checker.Interval.Duration <= 0 {
+		invalid("checker.interval", "must be greater than zero, got %s", c.Checker.Interval.Duration)
+	}
+
+	if c.Checker.NeighbourLimit < 0 {
+		invalid("checker.neighbourLimit", "must not be negative, got %d", c.Checker.NeighbourLimit)
+	}
+
+	c.validateSelection(invalid)
+
+	if c.Checker.HistoryDepth < 0 {
+		invalid("checker.historyDepth", "must not be negative, got %d", c.Checker.HistoryDepth)
+	}
+
+	if c.Checker.IngressURL != "" {
+		if err := validateURL(c.Checker.IngressURL); err != nil {
+			invalid("checker.ingressURL", "%s", err)
+		}
+	}
+
+	if c.Checker.ServiceURL != "" {
+		if err := validateURL(c.Checker.ServiceURL); err != nil {
+			invalid("checker.serviceURL", "%s", err)
+		}
+	}
+
+	c.validateDiscovery(invalid)
+	validateExtraChecks(c.Checks.Extra, invalid)
+	validateDNS(&c.Checks.DNS, invalid)
+	validateUDP(&c.Checks.UDP, invalid)
+
+	for i, size := range c.Checks.MTU.Sizes {
+		if size < 1 || size > maxMTUSize {
+			invalid(fmt.Sprintf("checks.mtu.sizes[%d]", i), "must be between 1 and %d, got %d", maxMTUSize, size)
+		}
+	}
+
+	if c.Checks.MTU.Timeout.Duration <= 0 {
+		invalid("checks.mtu.timeout", "must be greater than zero, got %s", c.Checks.MTU.Timeout.Duration)
+	}
+
+	if c.Checks.HostPath.Port < 1 || c.Checks.HostPath.Port > 65535 {
+		invalid("checks.hostPath.port", "must be a valid port, got %d", c.Checks.HostPath.Port)
+	}
+
+	if c.Checks.NodeHealth.KubeletPort < 1 || c.Checks.NodeHealth.KubeletPort > 65535 {
+		invalid("checks.nodeHealth.kubeletPort", "must be a valid port, got %d", c.Checks.NodeHealth.KubeletPort)
+	}
+
+	if c.Checks.NodeHealth.KubeProxyPort < 1 || c.Checks.NodeHealth.KubeProxyPort > 65535 {
+		invalid("checks.nodeHealth.kubeProxyPort", "must be a valid port, got %d", c.Checks.NodeHealth.KubeProxyPort)
+	}
+
+	if c.Checks.NeighbourhoodBurst.Probes < 1 {
+		invalid("checks.neighbourhoodBurst.probes", "must be at least 1, got %d", c.Checks.NeighbourhoodBurst.Probes)
+	}
+
+	if c.Checks.NeighbourhoodBurst.Gap.Duration < 0 {
+		invalid("checks.neighbourhoodBurst.gap", "must not be negative, got %s", c.Checks.NeighbourhoodBurst.Gap.Duration)
+	}
+
+	if c.Analysis.Interval.Duration <= 0 {
+		invalid("analysis.interval", "must be greater than zero, got %s", c.Analysis.Interval.Duration)
+	}
+
+	if c.Aggregator.Interval.Duration <= 0 {
+		invalid("aggregator.interval", "must be greater than zero, got %s", c.Aggregator.Interval.Duration)
+	}
+
+	validateNodeCondition(&c.NodeCondition, invalid)
+
+	if c.Metrics.OTLP.Endpoint != "" {
+		if err := validateURL(c.Metrics.OTLP.Endpoint); err != nil {
+			invalid("metrics.otlp.endpoint", "%s", err)
+		}
+	}
+
+	if c.Metrics.OTLP.Interval.Duration <= 0 {
+		invalid("metrics.otlp.interval", "must be greater than zero, got %s", c.Metrics.OTLP.Interval.Duration)
+	}
+
+	c.validatePush(invalid)
+
+	if c.Tracing.Endpoint != "" {
+		if err := validateURL(c.Tracing.Endpoint); err != nil {
+			invalid("tracing.endpoint", "%s", err)
+		}
+	}
+
+	if len(c.Metrics.HistogramBuckets) > 0 {
+		if err := metrics.ValidateBuckets(c.Metrics.HistogramBuckets); err != nil {
+			invalid("metrics.histogramBuckets", "%s", err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) validatePush(invalid invalidFunc) {
+	if c.Metrics.Push.URL != "" {
+		if err := validateURL(c.Metrics.Push.URL); err != nil {
+			invalid("metrics.push.url", "%s", err)
+		}
+	}
+
+	switch c.Metrics.Push.Format {
+	case PushRemoteWrite:
+	case PushImport:
+	default:
+		invalid("metrics.push.format", "must be %s or %s, got %q", PushRemoteWrite, PushImport, c.Metrics.Push.Format)
+	}
+
+	if c.Metrics.Push.Interval.Duration <= 0 {
+		invalid("metrics.push.interval", "must be greater than zero, got %s", c.Metrics.Push.Interval.Duration)
+	}
+
+	for name := range c.Metrics.Push.ExtraLabels {
+		switch {
+		case !validLabelName(name):
+			invalid("metrics.push.extraLabels", "invalid label name %q", name)
+		case name == "node" || name == "pod":
+			invalid("metrics.push.extraLabels", "the %s label is reserved for the %s of the kubenurse", name, name)
+		}
+	}
+}
+
+// validLabelName tells whether name is a Prometheus label name which isn't
+// reserved for internal use, i.e. doesn't start with __.
+func validLabelName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "__") {
+		return false
+	}
+
+	for i, r := range name {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *Config) validateSelection(invalid invalidFunc) {
+	switch c.Checker.NeighbourSelection {
+	case SelectionHash:
+	case SelectionZone:
+		if c.Discovery.Standalone() {
+			invalid("checker.neighbourSelection", "requires the Kubernetes API, and cannot be zone in the %s discovery mode", c.Discovery.Mode)
+		}
+	default:
+		invalid("checker.neighbourSelection", "unsupported strategy %q, must be hash or zone", c.Checker.NeighbourSelection)
+	}
+
+	if c.Checker.NeighbourRotation.Duration < 0 {
+		invalid("checker.neighbourRotation", "must not be negative, got %s", c.Checker.NeighbourRotation.Duration)
+	}
+
+	t := &c.Checker.NeighbourTopology
+
+	for _, f := range []struct {
+		field string
+		value int
+	}{
+		{"checker.neighbourTopology.sameZone", t.SameZone},
+		{"checker.neighbourTopology.perZone", t.PerZone},
+		{"checker.neighbourTopology.perRegion", t.PerRegion},
+	} {
+		if f.value < 0 {
+			invalid(f.field, "must not be negative, got %d", f.value)
+		}
+	}
+}
+
+func (c *Config) validateDiscovery(invalid invalidFunc) {
+	d := &c.Discovery
+
+	switch d.Mode {
+	case DiscoveryPods:
+	case DiscoveryEndpointSlices:
+		if d.Service == "" {
+			invalid("discovery.service", "must not be empty in the endpointSlices mode")
+		}
+	case DiscoveryStatic:
+		if len(d.Peers) == 0 {
+			invalid("discovery.peers", "must not be empty in the static mode")
+		}
+	case DiscoveryFile:
+		if d.PeersFile == "" {
+			invalid("discovery.peersFile", "must not be empty in the file mode")
+		}
+	case DiscoveryDNS:
+		if d.PeersDNS == "" {
+			invalid("discovery.peersDNS", "must not be empty in the dns mode")
+		}
+	default:
+		invalid("discovery.mode", "unsupported mode %q, must be pods, endpointSlices, static, file or dns", d.Mode)
+	}
+
+	if !d.Standalone() {
+		return
+	}
+
+	// the features which need the Kubernetes API
+	for _, f := range []struct {
+		field   string
+		enabled bool
+	}{
+		{"checks.clusterChecks", c.Checks.ClusterChecks},
+		{"checks.dns.enabled", c.Checks.DNS.Enabled},
+		{"checks.nodeHealth.kubelet", c.Checks.NodeHealth.Kubelet},
+		{"checks.nodeHealth.kubeProxy", c.Checks.NodeHealth.KubeProxy},
+		{"analysis.blame", c.Analysis.Blame},
+		{"analysis.partitions", c.Analysis.Partitions},
+		{"events.enabled", c.Events.Enabled},
+		{"nodeCondition.enabled", c.NodeCondition.Enabled},
+	} {
+		if f.enabled {
+			invalid(f.field, "requires the Kubernetes API, and cannot be enabled in the %s discovery mode", d.Mode)
+		}
+	}
+
+	// the checks through the node IP of the neighbours, which the peers don't have
+	for _, f := range []struct {
+		field   string
+		enabled bool
+	}{
+		{"checks.hostPath.enabled", c.Checks.HostPath.Enabled},
+		{"checks.nodeHealth.neighbours", c.Checks.NodeHealth.Neighbours},
+	} {
+		if f.enabled {
+			invalid(f.field, "requires the node IP of the neighbours, and cannot be enabled in the %s discovery mode", d.Mode)
+		}
+	}
+}
+
+func validateExtraChecks(extra []ExtraCheck, invalid invalidFunc) {
+	names := make(map[string]int, len(extra))
+
+	for i, ec := range extra {
+		field := fmt.Sprintf("checks.extra[%d]", i)
+
+		switch {
+		case ec.Name == "":
+			invalid(field+".name", "must not be empty")
+		case reservedCheckNames[ec.Name] || hasReservedPrefix(ec.Name):
+			invalid(field+".name", "%q is reserved for a built-in check", ec.Name)
+		case strings.Contains(ec.Name, "/"):
+			invalid(field+".name", "%q must not contain '/', which is reserved for KubenurseCheck resources", ec.Name)
+		default:
+			if j, dup := names[ec.Name]; dup {
+				invalid(field+".name", "%q is already used by checks.extra[%d]", ec.Name, j)
+			} else {
+				names[ec.Name] = i
+			}
+		}
+
+		if err := validateCheckURL(ec.URL); err != nil {
+			invalid(field+".url", "%s", err)
+		}
+
+		if ec.Method != "" && !validMethods[ec.Method] {
+			invalid(field+".method", "unsupported http method %q", ec.Method)
+		}
+
+		if ec.ExpectedStatus != 0 && (ec.ExpectedStatus < 100 || ec.ExpectedStatus > 599) {
+			invalid(field+".expectedStatus", "must be a valid http status code, got %d", ec.ExpectedStatus)
+		}
+
+		if ec.Interval.Duration < 0 {
+			invalid(field+".interval", "must not be negative, got %s", ec.Interval.Duration)
+		}
+
+		if ec.Timeout.Duration < 0 {
+			invalid(field+".timeout", "must not be negative, got %s", ec.Timeout.Duration)
+		}
+	}
+}
+
+func validateDNS(d *DNS, invalid invalidFunc) {
+	for i, q := range d.Queries {
+		field := fmt.Sprintf("checks.dns.queries[%d]", i)
+
+		if q.Name == "" {
+			invalid(field+".name", "must not be empty")
+		}
+
+		if q.Type != "A" && q.Type != "AAAA" && q.Type != "SRV" {
+			invalid(field+".type", "unsupported type %q, must be A, AAAA or SRV", q.Type)
+		}
+	}
+
+	if d.Enabled && (d.Namespace == "" || d.Service == "") {
+		invalid("checks.dns", "namespace and service are required when the DNS check is enabled")
+	}
+
+	if d.Timeout.Duration <= 0 {
+		invalid("checks.dns.timeout", "must be greater than zero, got %s", d.Timeout.Duration)
+	}
+}
+
+func validateUDP(u *UDP, invalid invalidFunc) {
+	if u.Port < 1 || u.Port > 65535 {
+		invalid("checks.udp.port", "must be a valid port, got %d", u.Port)
+	}
+
+	if u.Probes < 1 {
+		invalid("checks.udp.probes", "must be at least 1, got %d", u.Probes)
+	}
+
+	if u.Timeout.Duration <= 0 {
+		invalid("checks.udp.timeout", "must be greater than zero, got %s", u.Timeout.Duration)
+	}
+}
+
+func validateNodeCondition(n *NodeCondition, invalid invalidFunc) {
+	if n.FailureThreshold < 1 {
+		invalid("nodeCondition.failureThreshold", "must be at least 1, got %d", n.FailureThreshold)
+	}
+
+	if n.SuccessThreshold < 1 {
+		invalid("nodeCondition.successThreshold", "must be at least 1, got %d", n.SuccessThreshold)
+	}
+
+	if n.MaxPathFailureRatio < 0 || n.MaxPathFailureRatio > 1 {
+		invalid("nodeCondition.maxPathFailureRatio", "must be between 0 and 1, got %g", n.MaxPathFailureRatio)
+	}
+}
+
+func validateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q in %q, must be http or https", u.Scheme, s)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("missing host in %q", s)
+	}
+
+	return nil
+}
+
+// validateCheckURL validates the url of an extra check, which can also be a
+// tcp://host:port or tls://host:port url.
+func validateCheckURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return validateURL(s)
+	case "tcp", "tls":
+		if u.Hostname() == "" || u.Port() == "" {
+			return fmt.Errorf("missing host or port in %q, must be %s://host:port", s, u.Scheme)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unsupported scheme %q in %q, must be http, https, tcp or tls", u.Scheme, s)
+	}
+}
+
+func hasReservedPrefix(name string) bool {
+	for _, prefix := range reservedCheckPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,314 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "kubenurse.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+checker:
+  interval: 10s
+  ingressURL: https://kubenurse.example.com
+  neighbourLimit: 3
+checks:
+  meService: false
+  extra:
+  - name: google
+    url: https://www.google.ch/
+`)
+
+	t.Run("defaults", func(t *testing.T) {
+		r := require.New(t)
+
+		cfg, err := Load("")
+		r.NoError(err)
+		r.Equal(Default(), cfg)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		r := require.New(t)
+
+		cfg, err := Load(path)
+		r.NoError(err)
+		r.Equal(path, cfg.File)
+		r.Equal(10*time.Second, cfg.Checker.Interval.Duration)
+		r.Equal(3, cfg.Checker.NeighbourLimit)
+		r.Equal(5*time.Second, cfg.Server.ShutdownDuration.Duration, "defaults are kept for unset fields")
+		r.True(cfg.Checks.MeIngress)
+		r.False(cfg.Checks.MeService)
+		r.Equal([]ExtraCheck{{Name: "google", URL: "https://www.google.ch/"}}, cfg.Checks.Extra)
+	})
+
+	t.Run("environment overrides the file", func(t *testing.T) {
+		r := require.New(t)
+
+		t.Setenv("KUBENURSE_CHECK_INTERVAL", "1m")
+		t.Setenv("KUBENURSE_CHECK_ME_SERVICE", "true")
+		t.Setenv("KUBENURSE_CHECK_ME_INGRESS", "false")
+		t.Setenv("KUBENURSE_EXTRA_CHECKS", "a:http://a.example.com|b:http://b.example.com/b")
+		t.Setenv("KUBENURSE_DISCOVERY", "static")
+		t.Setenv("KUBENURSE_PEERS", "edge-1.example.com, 10.0.0.2:8080,")
+
+		cfg, err := Load(path)
+		r.NoError(err)
+		r.Equal(time.Minute, cfg.Checker.Interval.Duration)
+		r.True(cfg.Checks.MeService)
+		r.False(cfg.Checks.MeIngress)
+		r.Equal([]ExtraCheck{
+			{Name: "a", URL: "http://a.example.com"},
+			{Name: "b", URL: "http://b.example.com/b"},
+		}, cfg.Checks.Extra)
+		r.True(cfg.Discovery.Standalone())
+		r.Equal([]string{"edge-1.example.com", "10.0.0.2:8080"}, cfg.Discovery.Peers)
+	})
+
+	t.Run("metrics push labels", func(t *testing.T) {
+		r := require.New(t)
+
+		t.Setenv("KUBENURSE_PUSH_EXTRA_LABELS", "cluster=edge-1, env=prod")
+
+		cfg, err := Load("")
+		r.NoError(err)
+		r.Equal(map[string]string{"cluster": "edge-1", "env": "prod"}, cfg.Metrics.Push.ExtraLabels)
+	})
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := map[string]struct {
+		file    string
+		env     map[string]string
+		wantErr []string
+	}{
+		"unknown field": {
+			file:    "checker:\n  intervall: 5s\n",
+			wantErr: []string{`unknown field "intervall"`},
+		},
+		"invalid values": {
+			file: `
+checker:
+  interval: 0s
+  neighbourLimit: -1
+  serviceURL: kubenurse:8080
+checks:
+  extra:
+  - name: me_service
+    url: http://example.com
+  - name: dup
+    url: ftp://example.com
+  - name: dup
+    url: http://
+  - name: ns/name
+    url: http://example.com
+    method: FETCH
+    expectedStatus: 2000
+  - name: tcp
+    url: tcp://db.example.com
+  - name: tls
+    url: tls://smtp.example.com:465
+`,
+			wantErr: []string{
+				"checker.interval: must be greater than zero, got 0s",
+				"checker.neighbourLimit: must not be negative, got -1",
+				`checker.serviceURL: unsupported scheme "kubenurse"`,
+				`checks.extra[0].name: "me_service" is reserved for a built-in check`,
+				`checks.extra[1].url: unsupported scheme "ftp" in "ftp://example.com", must be http, https, tcp or tls`,
+				`checks.extra[2].name: "dup" is already used by checks.extra[1]`,
+				`checks.extra[2].url: missing host in "http://"`,
+				`checks.extra[3].name: "ns/name" must not contain '/'`,
+				`checks.extra[3].method: unsupported http method "FETCH"`,
+				"checks.extra[3].expectedStatus: must be a valid http status code, got 2000",
+				`checks.extra[4].url: missing host or port in "tcp://db.example.com", must be tcp://host:port`,
+			},
+		},
+		"invalid environment": {
+			env: map[string]string{
+				"KUBENURSE_CHECK_INTERVAL":    "5",
+				"KUBENURSE_NEIGHBOUR_LIMIT":   "ten",
+				"KUBENURSE_EXTRA_CHECKS":      "no-colon",
+				"KUBENURSE_HISTOGRAM_BUCKETS": "0.1,0.05",
+				"KUBENURSE_DNS_QUERIES":       "A",
+			},
+			wantErr: []string{
+				`KUBENURSE_CHECK_INTERVAL: time: missing unit in duration "5"`,
+				`KUBENURSE_NEIGHBOUR_LIMIT: strconv.Atoi: parsing "ten"`,
+				`KUBENURSE_EXTRA_CHECKS: missing colon ':' between metric name and url in "no-colon"`,
+				`KUBENURSE_DNS_QUERIES: missing colon ':' between query type and name in "A"`,
+			},
+		},
+		"invalid dns check": {
+			file: `
+checks:
+  dns:
+    enabled: true
+    service: ""
+    timeout: 0s
+    queries:
+    - name: kubernetes.default.svc.cluster.local
+      type: MX
+    - type: A
+`,
+			env: map[string]string{"KUBENURSE_DNS_QUERIES": ""},
+			wantErr: []string{
+				`checks.dns.queries[0].type: unsupported type "MX", must be A, AAAA or SRV`,
+				"checks.dns.queries[1].name: must not be empty",
+				"checks.dns: namespace and service are required when the DNS check is enabled",
+				"checks.dns.timeout: must be greater than zero, got 0s",
+			},
+		},
+		"invalid udp check": {
+			file: `
+checks:
+  udp:
+    port: 70000
+    probes: 0
+    timeout: -1s
+  extra:
+  - name: udp_node
+    url: http://example.com
+`,
+			wantErr: []string{
+				`checks.extra[0].name: "udp_node" is reserved for a built-in check`,
+				"checks.udp.port: must be a valid port, got 70000",
+				"checks.udp.probes: must be at least 1, got 0",
+				"checks.udp.timeout: must be greater than zero, got -1s",
+			},
+		},
+		"invalid node condition": {
+			file: `
+nodeCondition:
+  failureThreshold: 0
+  successThreshold: -1
+  maxPathFailureRatio: 1.5
+`,
+			wantErr: []string{
+				"nodeCondition.failureThreshold: must be at least 1, got 0",
+				"nodeCondition.successThreshold: must be at least 1, got -1",
+				"nodeCondition.maxPathFailureRatio: must be between 0 and 1, got 1.5",
+			},
+		},
+		"invalid discovery": {
+			file:    "discovery:\n  mode: consul\n",
+			wantErr: []string{`discovery.mode: unsupported mode "consul", must be pods, endpointSlices, static, file or dns`},
+		},
+		"invalid neighbour selection": {
+			file: `
+checker:
+  neighbourSelection: random
+  neighbourRotation: -1h
+  neighbourTopology:
+    perZone: -1
+`,
+			env: map[string]string{"KUBENURSE_NEIGHBOURS_PER_REGION": "-2"},
+			wantErr: []string{
+				`checker.neighbourSelection: unsupported strategy "random", must be hash or zone`,
+				"checker.neighbourRotation: must not be negative, got -1h0m0s",
+				"checker.neighbourTopology.perZone: must not be negative, got -1",
+				"checker.neighbourTopology.perRegion: must not be negative, got -2",
+			},
+		},
+		"zone selection in standalone": {
+			env: map[string]string{
+				"KUBENURSE_NEIGHBOUR_SELECTION": "zone",
+				"KUBENURSE_DISCOVERY":           "dns",
+				"KUBENURSE_PEERS_DNS":           "kubenurse.example.com",
+			},
+			wantErr: []string{"checker.neighbourSelection: requires the Kubernetes API, and cannot be zone in the dns discovery mode"},
+		},
+		"endpointSlices without service": {
+			env:     map[string]string{"KUBENURSE_DISCOVERY": "endpointSlices"},
+			wantErr: []string{"discovery.service: must not be empty in the endpointSlices mode"},
+		},
+		"standalone with kubernetes features": {
+			file: `
+discovery:
+  mode: static
+analysis:
+  blame: true
+  partitions: true
+checks:
+  hostPath:
+    enabled: true
+  nodeHealth:
+    neighbours: true
+`,
+			env: map[string]string{"KUBENURSE_EVENTS": "true"},
+			wantErr: []string{
+				"discovery.peers: must not be empty in the static mode",
+				"checks.hostPath.enabled: requires the node IP of the neighbours, and cannot be enabled in the static discovery mode",
+				"checks.nodeHealth.neighbours: requires the node IP of the neighbours, and cannot be enabled in the static discovery mode",
+				"analysis.blame: requires the Kubernetes API, and cannot be enabled in the static discovery mode",
+				"analysis.partitions: requires the Kubernetes API, and cannot be enabled in the static discovery mode",
+				"events.enabled: requires the Kubernetes API, and cannot be enabled in the static discovery mode",
+			},
+		},
+		"invalid tracing endpoint": {
+			env:     map[string]string{"KUBENURSE_TRACING_ENDPOINT": "otel-collector:4318"},
+			wantErr: []string{"tracing.endpoint: unsupported scheme"},
+		},
+		"invalid metrics push": {
+			file: `
+metrics:
+  push:
+    url: victoriametrics:8428
+    format: json
+    interval: 0s
+    extraLabels:
+      __name__: up
+      1st: a
+      pod: kubenurse-a
+`,
+			wantErr: []string{
+				`metrics.push.url: unsupported scheme "victoriametrics"`,
+				`metrics.push.format: must be remoteWrite or import, got "json"`,
+				"metrics.push.interval: must be greater than zero, got 0s",
+				`metrics.push.extraLabels: invalid label name "__name__"`,
+				`metrics.push.extraLabels: invalid label name "1st"`,
+				"metrics.push.extraLabels: the pod label is reserved for the pod of the kubenurse",
+			},
+		},
+		"invalid metrics push extra labels": {
+			env:     map[string]string{"KUBENURSE_PUSH_EXTRA_LABELS": "cluster=edge-1,env"},
+			wantErr: []string{`KUBENURSE_PUSH_EXTRA_LABELS: missing equal sign '=' between label name and value in "env"`},
+		},
+		"invalid histogram buckets": {
+			file:    "metrics:\n  histogramBuckets: [0.1, 0.05]\n",
+			wantErr: []string{"metrics.histogramBuckets:"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			path := ""
+			if tc.file != "" {
+				path = writeConfig(t, tc.file)
+			}
+
+			_, err := Load(path)
+			r.Error(err)
+
+			for _, want := range tc.wantErr {
+				r.ErrorContains(err, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyEnv overrides the configuration with the KUBENURSE_* environment
+// variables which are set. The boolean semantics are the same as before the
+// configuration file existed: features are enabled with "true", while the
+// checks are disabled with "false".
+func (c *Config) applyEnv() error {
+	var errs []error
+
+	envBool("KUBENURSE_USE_TLS", &c.Server.UseTLS)
+	envString("KUBENURSE_CERT_FILE", &c.Server.CertFile)
+	envString("KUBENURSE_CERT_KEY", &c.Server.CertKey)
+	errs = append(errs,
+		envDuration("KUBENURSE_SHUTDOWN_DURATION", &c.Server.ShutdownDuration.Duration),
+		envDuration("KUBENURSE_CHECK_INTERVAL", &c.Checker.Interval.Duration),
+		envDuration("KUBENURSE_NEIGHBOUR_ROTATION", &c.Checker.NeighbourRotation.Duration),
+	)
+
+	envBool("KUBENURSE_ALLOW_UNSCHEDULABLE", &c.Checker.AllowUnschedulable)
+	envString("KUBENURSE_INGRESS_URL", &c.Checker.IngressURL)
+	envString("KUBENURSE_SERVICE_URL", &c.Checker.ServiceURL)
+	envString("KUBENURSE_NAMESPACE", &c.Checker.Namespace)
+	envString("KUBENURSE_NODE_NAME", &c.Checker.NodeName)
+	envString("KUBENURSE_NEIGHBOUR_FILTER", &c.Checker.NeighbourFilter)
+	envString("KUBENURSE_NEIGHBOUR_SELECTION", &c.Checker.NeighbourSelection)
+	envString("KUBERNETES_SERVICE_DNS", &c.Checker.KubernetesServiceDNS)
+	envString("KUBERNETES_SERVICE_HOST", &c.Checker.KubernetesServiceHost)
+	envString("KUBERNETES_SERVICE_PORT", &c.Checker.KubernetesServicePort)
+
+	errs = append(errs,
+		envInt("KUBENURSE_NEIGHBOUR_LIMIT", &c.Checker.NeighbourLimit),
+		envInt("KUBENURSE_NEIGHBOURS_SAME_ZONE", &c.Checker.NeighbourTopology.SameZone),
+		envInt("KUBENURSE_NEIGHBOURS_PER_ZONE", &c.Checker.NeighbourTopology.PerZone),
+		envInt("KUBENURSE_NEIGHBOURS_PER_REGION", &c.Checker.NeighbourTopology.PerRegion),
+		envInt("KUBENURSE_HISTORY_DEPTH", &c.Checker.HistoryDepth),
+	)
+
+	envString("KUBENURSE_DISCOVERY", &c.Discovery.Mode)
+	envString("KUBENURSE_DISCOVERY_SERVICE", &c.Discovery.Service)
+	envStrings("KUBENURSE_PEERS", &c.Discovery.Peers)
+	envString("KUBENURSE_PEERS_FILE", &c.Discovery.PeersFile)
+	envString("KUBENURSE_PEERS_DNS", &c.Discovery.PeersDNS)
+
+	envCheck("KUBENURSE_CHECK_API_SERVER_DIRECT", &c.Checks.APIServerDirect)
+	envCheck("KUBENURSE_CHECK_API_SERVER_DNS", &c.Checks.APIServerDNS)
+	envCheck("KUBENURSE_CHECK_ME_INGRESS", &c.Checks.MeIngress)
+	envCheck("KUBENURSE_CHECK_ME_SERVICE", &c.Checks.MeService)
+	envCheck("KUBENURSE_CHECK_NEIGHBOURHOOD", &c.Checks.Neighbourhood)
+
+	errs = append(errs, envInt("KUBENURSE_NEIGHBOUR_BURST", &c.Checks.NeighbourhoodBurst.Probes))
+
+	if v := os.Getenv("KUBENURSE_EXTRA_CHECKS"); v != "" {
+		extra, err := parseExtraChecks(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("KUBENURSE_EXTRA_CHECKS: %w", err))
+		}
+
+		c.Checks.Extra = extra
+	}
+
+	envBool("KUBENURSE_CLUSTER_CHECKS", &c.Checks.ClusterChecks)
+	envBool("KUBENURSE_CHECK_DNS", &c.Checks.DNS.Enabled)
+	envString("KUBENURSE_DNS_NAMESPACE", &c.Checks.DNS.Namespace)
+	envString("KUBENURSE_DNS_SERVICE", &c.Checks.DNS.Service)
+
+	if v := os.Getenv("KUBENURSE_DNS_QUERIES"); v != "" {
+		queries, err := parseDNSQueries(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("KUBENURSE_DNS_QUERIES: %w", err))
+		}
+
+		c.Checks.DNS.Queries = queries
+	}
+
+	errs = append(errs, envInts("KUBENURSE_MTU_SIZES", &c.Checks.MTU.Sizes))
+
+	envBool("KUBENURSE_CHECK_KUBELET", &c.Checks.NodeHealth.Kubelet)
+	envBool("KUBENURSE_CHECK_KUBE_PROXY", &c.Checks.NodeHealth.KubeProxy)
+	envBool("KUBENURSE_CHECK_NEIGHBOUR_NODE_HEALTH", &c.Checks.NodeHealth.Neighbours)
+
+	envBool("KUBENURSE_CHECK_HOST_PATH", &c.Checks.HostPath.Enabled)
+	errs = append(errs, envInt("KUBENURSE_HOST_PATH_PORT", &c.Checks.HostPath.Port))
+
+	envBool("KUBENURSE_CHECK_UDP", &c.Checks.UDP.Enabled)
+
+	errs = append(errs, envInt("KUBENURSE_UDP_PORT", &c.Checks.UDP.Port))
+
+	envBool("KUBENURSE_BLAME_ANALYSIS", &c.Analysis.Blame)
+	envBool("KUBENURSE_PARTITION_DETECTION", &c.Analysis.Partitions)
+	errs = append(errs,
+		envDuration("KUBENURSE_ANALYSIS_INTERVAL", &c.Analysis.Interval.Duration),
+		envDuration("KUBENURSE_AGGREGATOR_INTERVAL", &c.Aggregator.Interval.Duration),
+	)
+
+	envBool("KUBENURSE_EVENTS", &c.Events.Enabled)
+	envBool("KUBENURSE_NODE_CONDITION", &c.NodeCondition.Enabled)
+
+	envBool("KUBENURSE_EXPOSE_METADATA", &c.Metrics.ExposeMetadata)
+	envBool("KUBENURSE_VICTORIAMETRICS_HISTOGRAM", &c.Metrics.VictoriaMetricsHistogram)
+
+	envString("KUBENURSE_OTLP_METRICS_ENDPOINT", &c.Metrics.OTLP.Endpoint)
+	errs = append(errs, envDuration("KUBENURSE_OTLP_METRICS_INTERVAL", &c.Metrics.OTLP.Interval.Duration))
+	envString("KUBENURSE_PUSH_URL", &c.Metrics.Push.URL)
+	envString("KUBENURSE_PUSH_FORMAT", &c.Metrics.Push.Format)
+	errs = append(errs,
+		envDuration("KUBENURSE_PUSH_INTERVAL", &c.Metrics.Push.Interval.Duration),
+		envLabels("KUBENURSE_PUSH_EXTRA_LABELS", &c.Metrics.Push.ExtraLabels),
+	)
+	envString("KUBENURSE_TRACING_ENDPOINT", &c.Tracing.Endpoint)
+	envString("KUBENURSE_CLUSTER_NAME", &c.Resource.ClusterName)
+	envString("KUBENURSE_POD_NAME", &c.Resource.PodName)
+
+	if v := os.Getenv("KUBENURSE_HISTOGRAM_BUCKETS"); v != "" {
+		var buckets []float64
+
+		for s := range strings.SplitSeq(v, ",") {
+			bucket, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("KUBENURSE_HISTOGRAM_BUCKETS: %w", err))
+				continue
+			}
+
+			buckets = append(buckets, bucket)
+		}
+
+		c.Metrics.HistogramBuckets = buckets
+	}
+
+	return errors.Join(errs...)
+}
+
+// parseExtraChecks parses the `<name>:<url>|<name>:<url>` format of KUBENURSE_EXTRA_CHECKS.
+func parseExtraChecks(s string) ([]ExtraCheck, error) {
+	var extra []ExtraCheck
+
+	for check := range strings.SplitSeq(s, "|") {
+		name, url, fnd := strings.Cut(check, ":")
+		if !fnd {
+			return nil, fmt.Errorf("missing colon ':' between metric name and url in %q", check)
+		}
+
+		extra = append(extra, ExtraCheck{Name: name, URL: url})
+	}
+
+	return extra, nil
+}
+
+// parseDNSQueries parses the `<type>:<name>,<type>:<name>` format of KUBENURSE_DNS_QUERIES.
+func parseDNSQueries(s string) ([]DNSQuery, error) {
+	var queries []DNSQuery
+
+	for query := range strings.SplitSeq(s, ",") {
+		qtype, name, fnd := strings.Cut(query, ":")
+		if !fnd {
+			return nil, fmt.Errorf("missing colon ':' between query type and name in %q", query)
+		}
+
+		queries = append(queries, DNSQuery{Name: name, Type: qtype})
+	}
+
+	return queries, nil
+}
+
+func envString(name string, dst *string) {
+	if v := os.Getenv(name); v != "" {
+		*dst = v
+	}
+}
+
+// envStrings parses a comma separated list of strings.
+func envStrings(name string, dst *[]string) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+
+	var strs []string
+
+	for s := range strings.SplitSeq(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			strs = append(strs, s)
+		}
+	}
+
+	*dst = strs
+}
+
+// envBool sets dst if the variable is set, a feature is only enabled with "true".
+func envBool(name string, dst *bool) {
+	if v, ok := os.LookupEnv(name); ok {
+		*dst = v == "true"
+	}
+}
+
+// envCheck sets dst if the variable is set, a check is only disabled with "false".
+func envCheck(name string, dst *bool) {
+	if v, ok := os.LookupEnv(name); ok {
+		*dst = v != "false"
+	}
+}
+
+func envInt(name string, dst *int) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	*dst = i
+
+	return nil
+}
+
+// envInts parses a comma separated list of integers.
+func envInts(name string, dst *[]int) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+
+	var ints []int
+
+	for s := range strings.SplitSeq(v, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		ints = append(ints, i)
+	}
+
+	*dst = ints
+
+	return nil
+}
+
+// envLabels parses a comma separated list of name=value labels.
+func envLabels(name string, dst *map[string]string) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+
+	labels := make(map[string]string)
+
+	for s := range strings.SplitSeq(v, ",") {
+		k, val, fnd := strings.Cut(strings.TrimSpace(s), "=")
+		if !fnd {
+			return fmt.Errorf("%s: missing equal sign '=' between label name and value in %q", name, s)
+		}
+
+		labels[k] = val
+	}
+
+	*dst = labels
+
+	return nil
+}
+
+func envDuration(name string, dst *time.Duration) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	*dst = d
+
+	return nil
+}
@@ -0,0 +1,409 @@
+package kubenurse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/postfinance/kubenurse/internal/config"
+	"github.com/postfinance/kubenurse/internal/servicecheck"
+	"github.com/postfinance/kubenurse/internal/util"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	clusterKubenurses   = "cluster_kubenurses"
+	clusterPathChecks   = "cluster_path_checks"
+	clusterBrokenPairs  = "cluster_broken_pairs"
+	clusterFailingNodes = "cluster_failing_nodes"
+)
+
+// Aggregator periodically collects the results of all the kubenurses, and
+// serves a cluster-wide summary and metrics, for the clusters without a
+// monitoring system which collects the metrics of every kubenurse.
+type Aggregator struct {
+	http http.Server
+
+	// checker discovers the kubenurses like the neighbourhood check, it
+	// doesn't run any check
+	checker *servicecheck.Checker
+
+	// httpClient queries the /alive endpoint of the kubenurses, on the port
+	// of their http listener unless the neighbour has its own port
+	httpClient *http.Client
+	port       string
+	interval   time.Duration
+
+	// summary is the result of the last collection, nil until the first one
+	summary atomic.Pointer[Summary]
+
+	// exported are the names of the exported blame gauges
+	exported map[string]bool
+	// partitions tracks the partitions of the successive collections
+	partitions partitionTracker
+
+	// meterProvider pushes the metrics with OTLP, nil if it is disabled
+	meterProvider *sdkmetric.MeterProvider
+	// pusher pushes the metrics with Prometheus remote-write or the
+	// VictoriaMetrics import API, nil if it is disabled
+	pusher *metricsPusher
+}
+
+// Summary is the cluster-wide view of the aggregator.
+type Summary struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Kubenurses is the number of discovered kubenurses, the errors of the
+	// mesh hold those which couldn't be queried
+	Kubenurses int `json:"kubenurses"`
+	// PathChecks is the number of path checks in the mesh
+	PathChecks int `json:"path_checks"`
+	// BrokenPairs are the failed path checks, sorted by source and destination
+	BrokenPairs []BrokenPair `json:"broken_pairs"`
+	// FailedChecks holds the failed checks by node, other than the path checks
+	FailedChecks map[string][]string `json:"failed_checks"`
+	Mesh         *Mesh               `json:"mesh"`
+}
+
+// BrokenPair is a failed path check from a source to a destination node.
+type BrokenPair struct {
+	Src   string `json:"src"`
+	Dst   string `json:"dst"`
+	Error string `json:"error"`
+}
+
+// NewAggregator creates the aggregator, which discovers the kubenurses with
+// the discovery configured in cfg.
+func NewAggregator(c client.Client, cfg *config.Config) (*Aggregator, error) {
+	chk, err := NewChecker(c, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+
+	a := &Aggregator{
+		http: http.Server{
+			Addr:              ":8080",
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      10 * time.Second,
+			IdleTimeout:       120 * time.Second,
+		},
+		checker:    chk,
+		httpClient: &http.Client{Timeout: meshRequestTimeout},
+		port:       "8080",
+		interval:   cfg.Aggregator.Interval.Duration,
+		exported:   make(map[string]bool),
+	}
+
+	if cfg.Metrics.ExposeMetadata {
+		metrics.ExposeMetadata(true)
+	}
+
+	if cfg.Metrics.OTLP.Endpoint != "" {
+		if a.meterProvider, err = newMeterProvider(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Metrics.Push.URL != "" {
+		a.pusher = newMetricsPusher(cfg)
+	}
+
+	mux.HandleFunc("/ready", a.readyHandler())
+	mux.HandleFunc("/alwayshappy", func(http.ResponseWriter, *http.Request) {})
+	mux.HandleFunc("/summary", a.summaryHandler())
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		metrics.WritePrometheus(w, true)
+	})
+	mux.Handle("/", http.RedirectHandler("/summary", http.StatusMovedPermanently))
+
+	return a, nil
+}
+
+// Run collects the results of the kubenurses every interval and serves the
+// summary, until ctx is done and Shutdown was called.
+func (a *Aggregator) Run(ctx context.Context) error {
+	var (
+		wg   sync.WaitGroup
+		errc = make(chan error, 1)
+	)
+
+	wg.Go(func() {
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+
+		for {
+			a.update(ctx)
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+
+	if a.pusher != nil {
+		wg.Go(func() { a.pusher.run(ctx) })
+	}
+
+	wg.Go(func() {
+		if err := a.http.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errc <- fmt.Errorf("listen http: %w", err)
+		}
+	})
+
+	slog.Info("kubenurse aggregator just started")
+
+	wg.Wait()
+	close(errc)
+
+	return <-errc
+}
+
+// Shutdown gracefully halts the http server of the aggregator.
+func (a *Aggregator) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := a.http.Shutdown(ctx); err != nil {
+		return fmt.Errorf("stop http server: %w", err)
+	}
+
+	if a.meterProvider != nil {
+		if err := a.meterProvider.Shutdown(ctx); err != nil {
+			return fmt.Errorf("stop meter provider: %w", err)
+		}
+	}
+
+	if a.pusher != nil { // pushes the metrics a last time
+		if err := a.pusher.shutdown(ctx); err != nil {
+			return fmt.Errorf("push metrics: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// update collects the results and exports the cluster metrics.
+func (a *Aggregator) update(ctx context.Context) {
+	summary, err := a.collect(ctx)
+	if err != nil {
+		slog.Error("cannot collect the results of the kubenurses", "err", err)
+		return
+	}
+
+	a.summary.Store(summary)
+
+	unreachable := len(summary.Mesh.Errors)
+	failingNodes := len(summary.FailedChecks)
+
+	metrics.GetOrCreateGauge(util.GenMetricsName(clusterKubenurses, "state", "ok"), nil).
+		Set(float64(summary.Kubenurses - unreachable))
+	metrics.GetOrCreateGauge(util.GenMetricsName(clusterKubenurses, "state", "unreachable"), nil).
+		Set(float64(unreachable))
+	metrics.GetOrCreateGauge(util.MetricsNamespace+"_"+clusterPathChecks, nil).Set(float64(summary.PathChecks))
+	metrics.GetOrCreateGauge(util.MetricsNamespace+"_"+clusterBrokenPairs, nil).Set(float64(len(summary.BrokenPairs)))
+	metrics.GetOrCreateGauge(util.MetricsNamespace+"_"+clusterFailingNodes, nil).Set(float64(failingNodes))
+
+	a.exported = exportSuspicion(summary.Mesh.Suspicion, a.exported)
+	a.partitions.observe(&summary.Mesh.Partitions)
+}
+
+// collect queries the last results of every kubenurse, at most
+// meshConcurrency at once.
+func (a *Aggregator) collect(ctx context.Context) (*Summary, error) {
+	neighbours, err := a.checker.Neighbours(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("discover the kubenurses: %w", err)
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, meshConcurrency)
+		summary = Summary{
+			Timestamp:    time.Now(),
+			Kubenurses:   len(neighbours),
+			BrokenPairs:  []BrokenPair{},
+			FailedChecks: make(map[string][]string),
+			Mesh: &Mesh{
+				Matrix: make(map[string]map[string]servicecheck.PathResult),
+				Errors: make(map[string]string),
+			},
+		}
+	)
+
+	for _, n := range neighbours {
+		sem <- struct{}{}
+
+		wg.Go(func() {
+			defer func() { <-sem }()
+
+			snapshot, err := a.fetchResults(ctx, n)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				slog.Error("cannot query the results of a kubenurse", "pod", n.PodName, "err", err)
+				summary.Mesh.Errors[n.PodName] = err.Error()
+
+				return
+			}
+
+			summary.Mesh.Matrix[n.NodeName] = snapshot.PathResults()
+
+			for name, res := range snapshot.Checks {
+				if res.Status == servicecheck.StatusError && !strings.HasPrefix(name, "path_") {
+					summary.FailedChecks[n.NodeName] = append(summary.FailedChecks[n.NodeName], name)
+				}
+			}
+
+			slices.Sort(summary.FailedChecks[n.NodeName])
+		})
+	}
+
+	wg.Wait()
+
+	summary.Mesh.complete()
+
+	for _, src := range slices.Sorted(maps.Keys(summary.Mesh.Matrix)) {
+		row := summary.Mesh.Matrix[src]
+		summary.PathChecks += len(row)
+
+		for _, dst := range slices.Sorted(maps.Keys(row)) {
+			if status := row[dst].Status; status != string(servicecheck.StatusOK) {
+				summary.BrokenPairs = append(summary.BrokenPairs, BrokenPair{Src: src, Dst: dst, Error: status})
+			}
+		}
+	}
+
+	return &summary, nil
+}
+
+// fetchResults returns the results of the last run of a kubenurse, from its
+// /alive endpoint.
+func (a *Aggregator) fetchResults(ctx context.Context, n *servicecheck.Neighbour) (*servicecheck.Snapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, meshRequestTimeout)
+	defer cancel()
+
+	port := a.port
+	if n.Port != 0 {
+		port = strconv.Itoa(n.Port)
+	}
+
+	url := "http://" + net.JoinHostPort(n.PodIP, port) + "/alive"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK { // also before the end of the first run
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var alive struct {
+		Result *servicecheck.Snapshot `json:"last_check_result"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&alive); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", url, err)
+	}
+
+	if alive.Result == nil {
+		return nil, fmt.Errorf("no results in %s", url)
+	}
+
+	return alive.Result, nil
+}
+
+// readyHandler answers with 200 OK once the results were collected.
+func (a *Aggregator) readyHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if a.summary.Load() == nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}
+}
+
+// summaryHandler returns the last summary as JSON, or as plain text with
+// ?format=text.
+func (a *Aggregator) summaryHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		summary := a.summary.Load()
+		if summary == nil {
+			http.Error(w, "the results of the kubenurses weren't collected yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.URL.Query().Get("format") == "text" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			summary.WriteText(w)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", " ")
+		_ = enc.Encode(summary)
+	}
+}
+
+// WriteText writes the summary as plain text: the broken pairs, the failed
+// checks by node and the mesh table.
+func (s *Summary) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "collected at %s from %d kubenurse(s), %d unreachable\n",
+		s.Timestamp.Format(time.RFC3339), s.Kubenurses, len(s.Mesh.Errors))
+	fmt.Fprintf(w, "%d of %d path check(s) failed, %d partition(s)\n",
+		len(s.BrokenPairs), s.PathChecks, len(s.Mesh.Partitions.Groups))
+
+	if len(s.BrokenPairs) > 0 {
+		fmt.Fprintln(w)
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SRC\tDST\tERROR")
+
+		for _, p := range s.BrokenPairs {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", p.Src, p.Dst, p.Error)
+		}
+
+		_ = tw.Flush()
+	}
+
+	if len(s.FailedChecks) > 0 {
+		fmt.Fprintln(w, "\nfailed checks:")
+
+		for _, node := range slices.Sorted(maps.Keys(s.FailedChecks)) {
+			fmt.Fprintf(w, "%s: %s\n", node, strings.Join(s.FailedChecks[node], ", "))
+		}
+	}
+
+	fmt.Fprintln(w)
+	s.Mesh.WriteTable(w)
+}
@@ -0,0 +1,113 @@
+package kubenurse
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/postfinance/kubenurse/internal/config"
+	"github.com/postfinance/kubenurse/internal/servicecheck"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAggregator(t *testing.T) {
+	r := require.New(t)
+
+	// the /alive endpoint of the kubenurse running on node-b
+	alive := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"hostname": "kubenurse-b",
+			"last_check_result": servicecheck.Snapshot{
+				Checks: map[string]servicecheck.Result{
+					"path_node-a": {Status: servicecheck.StatusOK, LatencySeconds: 0.0012},
+					"path_node-c": {Status: servicecheck.StatusError, Message: "connection refused"},
+					"me_ingress":  {Status: servicecheck.StatusError, Message: "timeout"},
+					"me_service":  {Status: servicecheck.StatusOK},
+				},
+				Timestamp: time.Now(),
+			},
+		})
+	}))
+	defer alive.Close()
+
+	newPod := func(name, node, ip string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-system", Labels: map[string]string{"app": "kubenurse"}},
+			Spec:       v1.PodSpec{NodeName: node},
+			Status:     v1.PodStatus{PodIP: ip, Phase: v1.PodRunning},
+		}
+	}
+
+	fakeClient := fake.NewFakeClient(
+		newPod("kubenurse-b", "node-b", "127.0.0.1"),
+		newPod("kubenurse-c", "node-c", "127.0.0.2"), // nothing listens there
+	)
+
+	t.Setenv("KUBENURSE_NAMESPACE", "kube-system")
+	t.Setenv("KUBENURSE_NEIGHBOUR_FILTER", "app=kubenurse")
+	t.Setenv("KUBENURSE_ALLOW_UNSCHEDULABLE", "true")
+
+	cfg, err := config.Load("")
+	r.NoError(err)
+
+	aggregator, err := NewAggregator(fakeClient, cfg)
+	r.NoError(err)
+
+	aggregator.port = strconv.Itoa(alive.Listener.Addr().(*net.TCPAddr).Port)
+
+	ts := httptest.NewServer(aggregator.http.Handler)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/ready")
+	r.NoError(err)
+	resp.Body.Close()
+	r.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+
+	aggregator.update(context.Background())
+
+	resp, err = http.Get(ts.URL + "/ready")
+	r.NoError(err)
+	resp.Body.Close()
+	r.Equal(http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(ts.URL + "/summary")
+	r.NoError(err)
+
+	var summary Summary
+
+	r.NoError(json.NewDecoder(resp.Body).Decode(&summary))
+	resp.Body.Close()
+
+	r.Equal(2, summary.Kubenurses)
+	r.Equal(2, summary.PathChecks)
+	r.Equal([]BrokenPair{{Src: "node-b", Dst: "node-c", Error: "connection refused"}}, summary.BrokenPairs)
+	r.Equal(map[string][]string{"node-b": {"me_ingress"}}, summary.FailedChecks)
+	r.Equal([]string{"node-a", "node-b", "node-c"}, summary.Mesh.Nodes)
+	r.Contains(summary.Mesh.Errors, "kubenurse-c")
+
+	resp, err = http.Get(ts.URL + "/summary?format=text")
+	r.NoError(err)
+
+	body, err := io.ReadAll(resp.Body)
+	r.NoError(err)
+	resp.Body.Close()
+
+	r.Contains(string(body), "from 2 kubenurse(s), 1 unreachable")
+	r.Contains(string(body), "1 of 2 path check(s) failed")
+	r.Regexp(`node-b\s+node-c\s+connection refused`, string(body))
+	r.Contains(string(body), "node-b: me_ingress")
+
+	r.InDelta(1, metrics.GetOrCreateGauge(`kubenurse_cluster_broken_pairs`, nil).Get(), 0)
+	r.InDelta(1, metrics.GetOrCreateGauge(`kubenurse_cluster_kubenurses{state="unreachable"}`, nil).Get(), 0)
+	r.InDelta(1, metrics.GetOrCreateGauge(`kubenurse_cluster_failing_nodes`, nil).Get(), 0)
+}
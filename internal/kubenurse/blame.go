@@ -0,0 +1,240 @@
+package kubenurse
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/postfinance/kubenurse/internal/config"
+	"github.com/postfinance/kubenurse/internal/util"
+)
+
+const (
+	nodeSuspectedFaulty   = "node_suspected_faulty"
+	sourceSuspectedFaulty = "source_suspected_faulty"
+
+	// suspicionThreshold is the score above which a node is considered
+	// broken when the other scores are computed.
+	suspicionThreshold = 0.5
+)
+
+// Suspicion is the blame attributed to a node, from the path checks of all
+// the kubenurses. Both scores are between 0 and 1.
+type Suspicion struct {
+	// Node is the ratio of the healthy sources whose path check to the node
+	// fails, it is high if the node itself is broken.
+	Node float64 `json:"node"`
+	// Source is the ratio of the path checks of the node which fail although
+	// the other sources reach the destination, it is high if the node cannot
+	// reach the others. A broken node has both scores high, while a node with
+	// only a high source score is broken in the outgoing direction only.
+	Source float64 `json:"source"`
+}
+
+// paths returns whether the path check from a source to a destination node
+// succeeded. The rows of the kubenurses which couldn't be queried are filled
+// with the incoming checks seen by the destinations, which only tell about
+// the successful checks.
+func (m *Mesh) paths() map[string]map[string]bool {
+	paths := make(map[string]map[string]bool, len(m.Matrix))
+
+	for src, row := range m.Matrix {
+		paths[src] = make(map[string]bool, len(row))
+
+		for dst, pr := range row {
+			if src != dst {
+				paths[src][dst] = pr.Status == "ok"
+			}
+		}
+	}
+
+	for dst, sources := range m.Incoming {
+		for _, src := range sources {
+			if _, queried := m.Matrix[src]; queried || src == dst {
+				continue
+			}
+
+			if paths[src] == nil {
+				paths[src] = make(map[string]bool)
+			}
+
+			paths[src][dst] = true
+		}
+	}
+
+	return paths
+}
+
+// blame correlates the path checks of all the kubenurses to tell apart a
+// broken node, which the others cannot reach, from a broken source, which
+// cannot reach the others. A failed check is first blamed on its source if
+// most of the other sources reach the destination, and the destinations are
+// then only blamed by the sources which aren't suspected themselves.
+func (m *Mesh) blame() map[string]Suspicion {
+	paths := m.paths()
+
+	// results of the checks by destination and source node
+	incoming := make(map[string]map[string]bool)
+
+	for src, row := range paths {
+		for dst, ok := range row {
+			if incoming[dst] == nil {
+				incoming[dst] = make(map[string]bool)
+			}
+
+			incoming[dst][src] = ok
+		}
+	}
+
+	othersFailureRatio := func(dst, except string) (float64, bool) {
+		total, failed := 0, 0
+
+		for src, ok := range incoming[dst] {
+			if src == except {
+				continue
+			}
+
+			total++
+
+			if !ok {
+				failed++
+			}
+		}
+
+		if total == 0 {
+			return 0, false
+		}
+
+		return float64(failed) / float64(total), true
+	}
+
+	suspicion := make(map[string]Suspicion)
+
+	for src, row := range paths {
+		evaluated, blamed := 0, 0
+
+		for dst, ok := range row {
+			ratio, known := othersFailureRatio(dst, src)
+			if !known {
+				continue
+			}
+
+			evaluated++
+
+			if !ok && ratio < suspicionThreshold {
+				blamed++
+			}
+		}
+
+		if evaluated > 0 {
+			suspicion[src] = Suspicion{Source: float64(blamed) / float64(evaluated)}
+		}
+	}
+
+	for dst, sources := range incoming {
+		total, failed := 0, 0
+
+		for src, ok := range sources {
+			if suspicion[src].Source >= suspicionThreshold {
+				continue
+			}
+
+			total++
+
+			if !ok {
+				failed++
+			}
+		}
+
+		sus := suspicion[dst]
+		if total > 0 {
+			sus.Node = float64(failed) / float64(total)
+		}
+
+		suspicion[dst] = sus
+	}
+
+	return suspicion
+}
+
+// runAnalysis periodically collects the mesh of all the kubenurses and
+// exposes the blame scores and the partitions of every node, as enabled in
+// the analysis configuration, until ctx is done. Only the analysisLeader
+// collects the mesh and exposes the results, so that the kubenurses are
+// queried once per interval and the metrics aren't duplicated.
+func (s *Server) runAnalysis(ctx context.Context, cfg config.Analysis) {
+	ticker := time.NewTicker(cfg.Interval.Duration)
+	defer ticker.Stop()
+
+	var (
+		exported   = make(map[string]bool)
+		partitions partitionTracker
+	)
+
+	hostname, _ := os.Hostname()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		pods, err := s.kubenursePods(ctx)
+		if err != nil {
+			slog.Error("cannot collect the mesh for the analysis", "err", err)
+			continue
+		}
+
+		if analysisLeader(pods) != hostname {
+			// a former leader must not expose its last results forever
+			exported = exportSuspicion(nil, exported)
+			partitions.reset()
+
+			continue
+		}
+
+		mesh := s.collectMesh(ctx, pods)
+		if ctx.Err() != nil { // shutting down, the mesh is incomplete
+			return
+		}
+
+		s.storeMesh(mesh)
+
+		if cfg.Blame {
+			exported = exportSuspicion(mesh.Suspicion, exported)
+		}
+
+		if cfg.Partitions {
+			partitions.observe(&mesh.Partitions)
+		}
+	}
+}
+
+// exportSuspicion sets the blame gauges of every node, and unregisters those
+// of the previously exported nodes which are gone. It returns the names of
+// the exported gauges.
+func exportSuspicion(suspicion map[string]Suspicion, exported map[string]bool) map[string]bool {
+	seen := make(map[string]bool, 2*len(suspicion))
+
+	for node, sus := range suspicion {
+		nodeName := util.GenMetricsName(nodeSuspectedFaulty, "node", node)
+		sourceName := util.GenMetricsName(sourceSuspectedFaulty, "node", node)
+
+		metrics.GetOrCreateGauge(nodeName, nil).Set(sus.Node)
+		metrics.GetOrCreateGauge(sourceName, nil).Set(sus.Source)
+
+		seen[nodeName], seen[sourceName] = true, true
+	}
+
+	// the nodes which left the cluster must not be blamed forever
+	for name := range exported {
+		if !seen[name] {
+			metrics.UnregisterMetric(name)
+		}
+	}
+
+	return seen
+}
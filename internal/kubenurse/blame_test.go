@@ -0,0 +1,89 @@
+package kubenurse
+
+import (
+	"testing"
+
+	"github.com/postfinance/kubenurse/internal/servicecheck"
+	"github.com/stretchr/testify/require"
+)
+
+// fullMesh returns the matrix of all the nodes checking each other, where the
+// checks from src to dst fail if failed(src, dst) is true.
+func fullMesh(nodes []string, failed func(src, dst string) bool) map[string]map[string]servicecheck.PathResult {
+	matrix := make(map[string]map[string]servicecheck.PathResult)
+
+	for _, src := range nodes {
+		matrix[src] = make(map[string]servicecheck.PathResult)
+
+		for _, dst := range nodes {
+			if src == dst {
+				continue
+			}
+
+			status := "ok"
+			if failed(src, dst) {
+				status = "connection refused"
+			}
+
+			matrix[src][dst] = servicecheck.PathResult{Status: status}
+		}
+	}
+
+	return matrix
+}
+
+func TestBlame(t *testing.T) {
+	nodes := []string{"a", "b", "c", "d"}
+
+	t.Run("healthy", func(t *testing.T) {
+		m := Mesh{Matrix: fullMesh(nodes, func(_, _ string) bool { return false })}
+
+		for _, sus := range m.blame() {
+			require.Zero(t, sus.Node)
+			require.Zero(t, sus.Source)
+		}
+	})
+
+	t.Run("broken node", func(t *testing.T) {
+		m := Mesh{Matrix: fullMesh(nodes, func(src, dst string) bool { return src == "d" || dst == "d" })}
+		sus := m.blame()
+
+		require.Equal(t, Suspicion{Node: 1, Source: 1}, sus["d"])
+		require.Equal(t, Suspicion{}, sus["a"])
+	})
+
+	t.Run("broken node which cannot be queried", func(t *testing.T) {
+		m := Mesh{Matrix: fullMesh(nodes, func(src, dst string) bool { return dst == "d" })}
+		delete(m.Matrix, "d")
+
+		sus := m.blame()
+
+		require.InDelta(t, 1, sus["d"].Node, 1e-9)
+		require.Zero(t, sus["b"].Node)
+		require.Zero(t, sus["b"].Source)
+	})
+
+	t.Run("broken source", func(t *testing.T) {
+		m := Mesh{Matrix: fullMesh(nodes, func(src, _ string) bool { return src == "a" })}
+		sus := m.blame()
+
+		require.Equal(t, Suspicion{Node: 0, Source: 1}, sus["a"])
+
+		for _, node := range []string{"b", "c", "d"} {
+			require.Equal(t, Suspicion{}, sus[node], node)
+		}
+	})
+
+	t.Run("incoming checks of a kubenurse which cannot be queried", func(t *testing.T) {
+		m := Mesh{
+			Matrix:   fullMesh(nodes, func(_, _ string) bool { return false }),
+			Incoming: map[string][]string{"a": {"d"}, "b": {"d"}},
+		}
+		delete(m.Matrix, "d")
+
+		paths := m.paths()
+
+		require.Equal(t, map[string]bool{"a": true, "b": true}, paths["d"])
+		require.Equal(t, Suspicion{}, m.blame()["d"])
+	})
+}
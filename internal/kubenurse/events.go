@@ -0,0 +1,204 @@
+package kubenurse
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/postfinance/kubenurse/internal/servicecheck"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ChecksHealthyCondition is the condition of the kubenurse pod which is
+	// false while any of its checks fails.
+	ChecksHealthyCondition v1.PodConditionType = "KubenurseChecksHealthy"
+
+	reasonCheckFailed    = "CheckFailed"
+	reasonCheckRecovered = "CheckRecovered"
+)
+
+// nodeCheckPrefixes are the prefixes of the checks which are performed once
+// per neighbour, and whose transitions are reported on the neighbour node.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var nodeCheckPrefixes = []string{"path_", "host_path_", "mtu_", "udp_", "kubelet_", "kube_proxy_"}
+
+// checkReporter reports the transitions of the checks between ok and error as
+// Kubernetes events and as the ChecksHealthyCondition of the kubenurse pod.
+// The events are deduplicated and rate limited by the event broadcaster.
+type checkReporter struct {
+	recorder record.EventRecorder
+	client   client.Client
+
+	namespace string
+	podName   string
+	// pod is looked up lazily, it is nil until it was found
+	pod *v1.Pod
+
+	// failing holds the checks which failed during the previous run
+	failing map[string]bool
+}
+
+// UseEventRecorder enables the reporting of the check transitions with the
+// given recorder. It must be called before Run.
+func (s *Server) UseEventRecorder(recorder record.EventRecorder) {
+	hostname, _ := os.Hostname()
+
+	s.reporter = &checkReporter{
+		recorder:  recorder,
+		client:    s.client,
+		namespace: s.checker.KubenurseNamespace,
+		podName:   hostname,
+		failing:   make(map[string]bool),
+	}
+}
+
+// report compares the results of the last run with the previous one, and
+// emits an event for every check which failed or recovered. A check which
+// isn't performed anymore, e.g. because the neighbour left, is forgotten.
+func (r *checkReporter) report(ctx context.Context, results map[string]servicecheck.Result) {
+	if !r.lookupPod(ctx) {
+		return
+	}
+
+	failing := make(map[string]bool, len(r.failing))
+	changed := false
+
+	for name, res := range results {
+		if res.Status == servicecheck.StatusSkipped {
+			continue
+		}
+
+		failed := res.Status == servicecheck.StatusError
+		if failed {
+			failing[name] = true
+		}
+
+		if failed == r.failing[name] {
+			continue
+		}
+
+		changed = true
+
+		if failed {
+			r.recorder.Eventf(r.involvedObject(name), v1.EventTypeWarning, reasonCheckFailed,
+				"check %s of kubenurse %s failed: %s", name, r.podName, res.Message)
+		} else {
+			r.recorder.Eventf(r.involvedObject(name), v1.EventTypeNormal, reasonCheckRecovered,
+				"check %s of kubenurse %s recovered", name, r.podName)
+		}
+	}
+
+	if changed || len(failing) != len(r.failing) {
+		if err := r.updateCondition(ctx, failing); err != nil {
+			slog.Error("cannot update the condition of the kubenurse pod", "condition", ChecksHealthyCondition, "err", err)
+			return // retried on the next run
+		}
+	}
+
+	r.failing = failing
+}
+
+// involvedObject returns the neighbour node for the checks performed once per
+// neighbour, the local node for the node component checks, and the kubenurse
+// pod for the others.
+func (r *checkReporter) involvedObject(check string) *v1.ObjectReference {
+	node := ""
+
+	switch check {
+	case servicecheck.Kubelet, servicecheck.KubeProxy:
+		node = r.pod.Spec.NodeName
+	default:
+		for _, prefix := range nodeCheckPrefixes {
+			if n, ok := strings.CutPrefix(check, prefix); ok {
+				node = n
+				break
+			}
+		}
+	}
+
+	if node != "" {
+		// like the kubelet, the node name is used as UID, which is what
+		// kubectl describe node looks for
+		return &v1.ObjectReference{Kind: "Node", Name: node, UID: types.UID(node)}
+	}
+
+	return &v1.ObjectReference{
+		Kind:            "Pod",
+		APIVersion:      "v1",
+		Namespace:       r.pod.Namespace,
+		Name:            r.pod.Name,
+		UID:             r.pod.UID,
+		ResourceVersion: r.pod.ResourceVersion,
+	}
+}
+
+func (r *checkReporter) lookupPod(ctx context.Context) bool {
+	if r.pod != nil {
+		return true
+	}
+
+	pod := v1.Pod{}
+	if err := r.client.Get(ctx, client.ObjectKey{Namespace: r.namespace, Name: r.podName}, &pod); err != nil {
+		slog.Error("cannot get the kubenurse pod, the check transitions are not reported", "pod", r.podName, "err", err)
+		return false
+	}
+
+	r.pod = &pod
+
+	return true
+}
+
+// updateCondition sets the ChecksHealthyCondition of the kubenurse pod,
+// listing the failing checks in its message.
+func (r *checkReporter) updateCondition(ctx context.Context, failing map[string]bool) error {
+	pod := v1.Pod{}
+	if err := r.client.Get(ctx, client.ObjectKeyFromObject(r.pod), &pod); err != nil {
+		return err
+	}
+
+	cond := v1.PodCondition{
+		Type:               ChecksHealthyCondition,
+		Status:             v1.ConditionTrue,
+		Reason:             "ChecksOK",
+		Message:            "all checks succeeded",
+		LastTransitionTime: metav1.Now(),
+	}
+
+	if len(failing) > 0 {
+		names := make([]string, 0, len(failing))
+		for name := range failing {
+			names = append(names, name)
+		}
+
+		slices.Sort(names)
+
+		cond.Status = v1.ConditionFalse
+		cond.Reason = "ChecksFailing"
+		cond.Message = fmt.Sprintf("failing checks: %s", strings.Join(names, ", "))
+	}
+
+	patch := client.StrategicMergeFrom(pod.DeepCopy())
+
+	i := slices.IndexFunc(pod.Status.Conditions, func(c v1.PodCondition) bool { return c.Type == ChecksHealthyCondition })
+
+	switch {
+	case i < 0:
+		pod.Status.Conditions = append(pod.Status.Conditions, cond)
+	case pod.Status.Conditions[i].Status == cond.Status:
+		cond.LastTransitionTime = pod.Status.Conditions[i].LastTransitionTime
+		pod.Status.Conditions[i] = cond
+	default:
+		pod.Status.Conditions[i] = cond
+	}
+
+	return r.client.Status().Patch(ctx, &pod, patch)
+}
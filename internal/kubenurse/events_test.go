@@ -0,0 +1,104 @@
+package kubenurse
+
+import (
+	"context"
+	"testing"
+
+	"github.com/postfinance/kubenurse/internal/servicecheck"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCheckReporter(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubenurse-a", Namespace: "kube-system", UID: "uid-a"},
+		Spec:       v1.PodSpec{NodeName: "node-a"},
+	}
+
+	fakeClient := fake.NewClientBuilder().WithObjects(pod).WithStatusSubresource(pod).Build()
+	recorder := record.NewFakeRecorder(10)
+	recorder.IncludeObject = true
+
+	reporter := checkReporter{
+		recorder:  recorder,
+		client:    fakeClient,
+		namespace: "kube-system",
+		podName:   "kubenurse-a",
+		failing:   make(map[string]bool),
+	}
+
+	condition := func() *v1.PodCondition {
+		p := v1.Pod{}
+		r.NoError(fakeClient.Get(ctx, client.ObjectKeyFromObject(pod), &p))
+
+		for i := range p.Status.Conditions {
+			if p.Status.Conditions[i].Type == ChecksHealthyCondition {
+				return &p.Status.Conditions[i]
+			}
+		}
+
+		return nil
+	}
+
+	// the first run only reports the failures
+	reporter.report(ctx, checkResults(map[string]string{
+		"api_server_direct": "ok",
+		"me_service":        "skipped",
+		"path_node-b":       "connection refused",
+	}))
+
+	r.Len(recorder.Events, 1)
+	r.Equal("Warning CheckFailed check path_node-b of kubenurse kubenurse-a failed: connection refused "+
+		"involvedObject{kind=Node,apiVersion=}", <-recorder.Events)
+	r.Equal(v1.ConditionFalse, condition().Status)
+	r.Equal("failing checks: path_node-b", condition().Message)
+
+	// unchanged results aren't reported again
+	reporter.report(ctx, checkResults(map[string]string{"api_server_direct": "ok", "path_node-b": "connection refused"}))
+	r.Empty(recorder.Events)
+
+	reporter.report(ctx, checkResults(map[string]string{"api_server_direct": "timeout", "path_node-b": "ok", "kubelet": "ok"}))
+	r.Len(recorder.Events, 2)
+
+	events := []string{<-recorder.Events, <-recorder.Events}
+	r.ElementsMatch([]string{
+		"Warning CheckFailed check api_server_direct of kubenurse kubenurse-a failed: timeout involvedObject{kind=Pod,apiVersion=v1}",
+		"Normal CheckRecovered check path_node-b of kubenurse kubenurse-a recovered involvedObject{kind=Node,apiVersion=}",
+	}, events)
+	r.Equal("failing checks: api_server_direct", condition().Message)
+
+	// a check which isn't performed anymore is forgotten
+	reporter.report(ctx, checkResults(map[string]string{"path_node-b": "ok"}))
+	r.Empty(recorder.Events)
+	r.Equal(v1.ConditionTrue, condition().Status)
+
+	r.Equal("node-a", reporter.involvedObject("kubelet").Name)
+	r.Equal("node-c", reporter.involvedObject("kube_proxy_node-c").Name)
+	r.Equal("kubenurse-a", reporter.involvedObject("dns_10.0.0.10").Name)
+}
+
+// checkResults builds the results of a run from the strings returned by the
+// checks, which are either ok, skipped or the error message.
+func checkResults(checks map[string]string) map[string]servicecheck.Result {
+	results := make(map[string]servicecheck.Result, len(checks))
+
+	for name, s := range checks {
+		switch s {
+		case "ok":
+			results[name] = servicecheck.Result{Status: servicecheck.StatusOK}
+		case "skipped":
+			results[name] = servicecheck.Result{Status: servicecheck.StatusSkipped}
+		default:
+			results[name] = servicecheck.Result{Status: servicecheck.StatusError, Message: s}
+		}
+	}
+
+	return results
+}
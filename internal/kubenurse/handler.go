@@ -2,8 +2,13 @@ package kubenurse
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"path"
+	"strconv"
+	"time"
 
 	"github.com/postfinance/kubenurse/internal/servicecheck"
 )
@@ -21,15 +26,15 @@ func (s *Server) readyHandler() func(w http.ResponseWriter, r *http.Request) {
 func (s *Server) aliveHandler() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		type Output struct {
-			Hostname   string              `json:"hostname"`
-			Headers    map[string][]string `json:"headers"`
-			UserAgent  string              `json:"user_agent"`
-			RequestURI string              `json:"request_uri"`
-			RemoteAddr string              `json:"remote_addr"`
-			Result     map[string]any      `json:"last_check_result"`
+			Hostname   string                 `json:"hostname"`
+			Headers    map[string][]string    `json:"headers"`
+			UserAgent  string                 `json:"user_agent"`
+			RequestURI string                 `json:"request_uri"`
+			RemoteAddr string                 `json:"remote_addr"`
+			Result     *servicecheck.Snapshot `json:"last_check_result"`
 		}
 
-		res := s.checker.LastCheckResult
+		res := s.checker.LastResults()
 		if res == nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
@@ -52,11 +57,99 @@ func (s *Server) aliveHandler() func(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// historyHandler returns the past results of the checks as JSON, oldest
+// first. The results can be filtered with the query parameters check, a glob
+// pattern which can be repeated, status, since and until, which are either
+// RFC 3339 timestamps or durations before now, e.g. since=15m.
+func (s *Server) historyHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseHistoryFilter(r, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", " ")
+		_ = enc.Encode(s.checker.History(filter))
+	}
+}
+
+func parseHistoryFilter(r *http.Request, now time.Time) (servicecheck.HistoryFilter, error) {
+	q := r.URL.Query()
+	filter := servicecheck.HistoryFilter{
+		Checks: q["check"],
+		Status: servicecheck.Status(q.Get("status")),
+	}
+
+	for _, pattern := range filter.Checks {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return filter, fmt.Errorf("invalid check pattern %q: %w", pattern, err)
+		}
+	}
+
+	switch filter.Status {
+	case "", servicecheck.StatusOK, servicecheck.StatusError, servicecheck.StatusSkipped:
+	default:
+		return filter, fmt.Errorf("status must be one of %s, %s or %s", servicecheck.StatusOK,
+			servicecheck.StatusError, servicecheck.StatusSkipped)
+	}
+
+	var err error
+
+	if filter.Since, err = parseHistoryTime(q.Get("since"), now); err != nil {
+		return filter, fmt.Errorf("invalid since: %w", err)
+	}
+
+	if filter.Until, err = parseHistoryTime(q.Get("until"), now); err != nil {
+		return filter, fmt.Errorf("invalid until: %w", err)
+	}
+
+	return filter, nil
+}
+
+// parseHistoryTime parses either an RFC 3339 timestamp or a duration before
+// now, the empty string is the zero time.
+func parseHistoryTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+// alwaysHappyHandler answers with 200 OK. With the size query parameter, used
+// by the MTU check, the request body is discarded and a body of size bytes is
+// returned.
 func (s *Server) alwaysHappyHandler() func(w http.ResponseWriter, r *http.Request) {
-	return func(_ http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		span := s.traceRequest(r)
+		defer span.End()
+
 		origin := r.Header.Get(servicecheck.NeighbourOriginHeader)
 		if origin != "" {
 			s.neighboursTTLCache.Insert(origin)
 		}
+
+		if !r.URL.Query().Has("size") {
+			return
+		}
+
+		size, err := strconv.Atoi(r.URL.Query().Get("size"))
+		if err != nil || size < 0 || size > servicecheck.MaxPayloadSize {
+			http.Error(w, fmt.Sprintf("size must be between 0 and %d", servicecheck.MaxPayloadSize), http.StatusBadRequest)
+			return
+		}
+
+		_, _ = io.Copy(io.Discard, io.LimitReader(r.Body, servicecheck.MaxPayloadSize))
+
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		_, _ = w.Write(make([]byte, size))
 	}
 }
@@ -1,10 +1,15 @@
 package kubenurse
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 
+	"github.com/postfinance/kubenurse/internal/config"
+	"github.com/postfinance/kubenurse/internal/servicecheck"
 	"github.com/stretchr/testify/require"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
@@ -13,7 +18,10 @@ func TestServerHandler(t *testing.T) {
 	r := require.New(t)
 
 	fakeClient := fake.NewFakeClient()
-	kubenurse, err := New(fakeClient)
+	cfg, err := config.Load("")
+	r.NoError(err)
+
+	kubenurse, err := New(fakeClient, cfg)
 
 	r.NoError(err)
 	r.NotNil(kubenurse)
@@ -37,6 +45,24 @@ func TestServerHandler(t *testing.T) {
 		"/alwayshappy": {
 			wantCode: http.StatusOK,
 		},
+		"/alwayshappy?size=1500": {
+			wantCode: http.StatusOK,
+		},
+		"/alwayshappy?size=100000": {
+			wantCode: http.StatusBadRequest,
+		},
+		"/history?check=path_*&status=error&since=15m": {
+			wantCode: http.StatusOK,
+		},
+		"/history?since=yesterday": {
+			wantCode: http.StatusBadRequest,
+		},
+		"/history?status=unknown": {
+			wantCode: http.StatusBadRequest,
+		},
+		"/history?check=[": {
+			wantCode: http.StatusBadRequest,
+		},
 		// TODO: also test that metrics are present
 		"/metrics": {
 			wantCode: http.StatusOK,
@@ -59,3 +85,83 @@ func TestServerHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestHostHandler(t *testing.T) {
+	r := require.New(t)
+
+	cfg, err := config.Load("")
+	r.NoError(err)
+
+	kubenurse, err := New(fake.NewFakeClient(), cfg)
+	r.NoError(err)
+
+	ts := httptest.NewServer(kubenurse.host.Handler)
+	defer ts.Close()
+
+	for path, wantCode := range map[string]int{
+		"/alwayshappy":           http.StatusOK,
+		"/alwayshappy?size=1500": http.StatusOK,
+		"/":                      http.StatusNotFound,
+		"/alive":                 http.StatusNotFound,
+		"/history":               http.StatusNotFound,
+		"/mesh":                  http.StatusNotFound,
+		"/mesh/local":            http.StatusNotFound,
+		"/metrics":               http.StatusNotFound,
+	} {
+		res, err := http.Get(ts.URL + path)
+		r.NoError(err)
+
+		res.Body.Close()
+		r.Equal(wantCode, res.StatusCode, path)
+	}
+}
+
+func TestAliveHandler(t *testing.T) {
+	r := require.New(t)
+
+	cfg, err := config.Load("")
+	r.NoError(err)
+
+	cfg.Checks.APIServerDirect = false
+	cfg.Checks.APIServerDNS = false
+
+	kubenurse, err := New(fake.NewFakeClient(), cfg)
+	r.NoError(err)
+
+	ts := httptest.NewServer(kubenurse.http.Handler)
+	defer ts.Close()
+
+	// the results are read while the checks run
+	var wg sync.WaitGroup
+
+	wg.Go(func() {
+		for range 3 {
+			kubenurse.checker.Run(context.Background())
+		}
+	})
+
+	for range 10 {
+		resp, err := http.Get(ts.URL + "/alive")
+		r.NoError(err)
+		resp.Body.Close()
+	}
+
+	wg.Wait()
+
+	resp, err := http.Get(ts.URL + "/alive")
+	r.NoError(err)
+
+	defer resp.Body.Close()
+
+	r.Equal(http.StatusOK, resp.StatusCode)
+
+	var out struct {
+		Result servicecheck.Snapshot `json:"last_check_result"`
+	}
+
+	r.NoError(json.NewDecoder(resp.Body).Decode(&out))
+	r.Equal(servicecheck.StatusSkipped, out.Result.Checks[servicecheck.APIServerDirect].Status)
+	r.Equal(servicecheck.StatusError, out.Result.Checks["me_service"].Status)
+	r.NotEmpty(out.Result.Checks["me_service"].Message)
+	r.False(out.Result.Timestamp.IsZero())
+}
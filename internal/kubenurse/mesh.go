@@ -0,0 +1,359 @@
+package kubenurse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/postfinance/kubenurse/internal/servicecheck"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	meshRequestTimeout = 2 * time.Second
+	// meshConcurrency is the maximum number of kubenurses queried at once
+	meshConcurrency = 16
+)
+
+// LocalMesh is the row of the mesh matrix of a single kubenurse, i.e. the
+// results of its path checks by destination node.
+type LocalMesh struct {
+	Node  string                             `json:"node"`
+	Paths map[string]servicecheck.PathResult `json:"paths"`
+	// Incoming holds the pods of the kubenurses whose path check reached
+	// this kubenurse during the last minute
+	Incoming []string `json:"incoming,omitempty"`
+}
+
+// Mesh is the source to destination matrix of the path checks of all the
+// kubenurses.
+type Mesh struct {
+	// Nodes are all the source and destination nodes, sorted
+	Nodes []string `json:"nodes"`
+	// Matrix holds the path results by source and destination node
+	Matrix map[string]map[string]servicecheck.PathResult `json:"matrix"`
+	// Errors holds the kubenurse pods which couldn't be queried
+	Errors map[string]string `json:"errors,omitempty"`
+	// Incoming holds the source nodes whose path check reached the
+	// kubenurse of a destination node during the last minute
+	Incoming map[string][]string `json:"incoming,omitempty"`
+	// Suspicion holds the blame scores of every node, see Mesh.blame
+	Suspicion map[string]Suspicion `json:"suspicion"`
+	// Partitions are the groups of nodes connected by the path checks
+	Partitions Partitions `json:"partitions"`
+}
+
+// meshLocalHandler returns the results of the path checks of this kubenurse.
+func (s *Server) meshLocalHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		node, paths := s.checker.PathResults()
+		incoming := s.neighboursTTLCache.ActiveKeys()
+
+		slices.Sort(incoming)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(LocalMesh{Node: node, Paths: paths, Incoming: incoming})
+	}
+}
+
+// meshHandler returns the matrix assembled from the /mesh/local endpoint of
+// every kubenurse as JSON, or as a plain-text table with ?format=text.
+func (s *Server) meshHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mesh, err := s.cachedMesh()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if r.URL.Query().Get("format") == "text" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			mesh.WriteTable(w)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", " ")
+		_ = enc.Encode(mesh)
+	}
+}
+
+// cachedMesh returns the last collected mesh if it is younger than
+// meshCacheTTL, and collects it otherwise, so that the hits of /mesh don't
+// query all the kubenurses every time. Concurrent calls wait for the same
+// collection, which is thus not bound to the request of one of them.
+func (s *Server) cachedMesh() (*Mesh, error) {
+	s.meshCacheMu.Lock()
+	defer s.meshCacheMu.Unlock()
+
+	if s.meshCache != nil && time.Since(s.meshCacheAt) < s.meshCacheTTL {
+		return s.meshCache, nil
+	}
+
+	listCtx, cancel := context.WithTimeout(context.Background(), meshRequestTimeout)
+	defer cancel()
+
+	pods, err := s.kubenursePods(listCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	// every batch of meshConcurrency kubenurses is queried within
+	// meshRequestTimeout
+	batches := time.Duration(len(pods)/meshConcurrency + 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), batches*meshRequestTimeout)
+	defer cancel()
+
+	mesh := s.collectMesh(ctx, pods)
+	if ctx.Err() != nil { // an incomplete mesh is not cached
+		return mesh, nil
+	}
+
+	s.meshCache, s.meshCacheAt = mesh, time.Now()
+
+	return mesh, nil
+}
+
+// storeMesh caches the mesh collected by the analysis.
+func (s *Server) storeMesh(mesh *Mesh) {
+	s.meshCacheMu.Lock()
+	defer s.meshCacheMu.Unlock()
+
+	s.meshCache, s.meshCacheAt = mesh, time.Now()
+}
+
+// kubenursePods lists the pods of all the kubenurses.
+func (s *Server) kubenursePods(ctx context.Context) ([]v1.Pod, error) {
+	if s.client == nil {
+		return nil, errors.New("the mesh requires the Kubernetes API, it is not available in the standalone mode")
+	}
+
+	pods := v1.PodList{}
+	cfg := s.currentConfig()
+	selector, _ := labels.Parse(cfg.Checker.NeighbourFilter)
+
+	if err := s.client.List(ctx, &pods, &client.ListOptions{
+		LabelSelector: selector,
+		Namespace:     cfg.Checker.Namespace,
+	}); err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+
+	return pods.Items, nil
+}
+
+// collectMesh queries every running kubenurse pod, at most meshConcurrency at
+// once.
+func (s *Server) collectMesh(ctx context.Context, pods []v1.Pod) *Mesh {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, meshConcurrency)
+		mesh = Mesh{
+			Matrix:   make(map[string]map[string]servicecheck.PathResult),
+			Errors:   make(map[string]string),
+			Incoming: make(map[string][]string),
+		}
+		podNodes = make(map[string]string, len(pods))
+		incoming = make(map[string][]string) // incoming pods by destination node
+	)
+
+	for i := range pods {
+		podNodes[pods[i].Name] = pods[i].Spec.NodeName
+	}
+
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase != v1.PodRunning || pod.Status.PodIP == "" {
+			continue
+		}
+
+		sem <- struct{}{}
+
+		wg.Go(func() {
+			defer func() { <-sem }()
+
+			local, err := s.fetchLocalMesh(ctx, pod.Status.PodIP)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				slog.Error("cannot query the mesh results of a kubenurse", "pod", pod.Name, "err", err)
+				mesh.Errors[pod.Name] = err.Error()
+
+				return
+			}
+
+			if local.Node == "" { // the kubenurse didn't discover its neighbours yet
+				local.Node = pod.Spec.NodeName
+			}
+
+			mesh.Matrix[local.Node] = local.Paths
+			incoming[local.Node] = local.Incoming
+		})
+	}
+
+	wg.Wait()
+
+	for dst, srcPods := range incoming {
+		for _, pod := range srcPods {
+			if src, ok := podNodes[pod]; ok && src != "" {
+				mesh.Incoming[dst] = append(mesh.Incoming[dst], src)
+			}
+		}
+	}
+
+	mesh.complete()
+
+	return &mesh
+}
+
+// analysisLeader returns the name of the running kubenurse pod which sorts
+// first, which runs the analysis for all the kubenurses. The leader changes
+// when it stops running, without the need of a lease.
+func analysisLeader(pods []v1.Pod) string {
+	leader := ""
+
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase != v1.PodRunning || pod.Status.PodIP == "" || pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		if leader == "" || pod.Name < leader {
+			leader = pod.Name
+		}
+	}
+
+	return leader
+}
+
+// complete lists the nodes of the matrix and of the incoming checks, and
+// computes their blame scores and partitions.
+func (m *Mesh) complete() {
+	nodes := make(map[string]bool)
+
+	for src, paths := range m.Matrix {
+		nodes[src] = true
+
+		for dst := range paths {
+			nodes[dst] = true
+		}
+	}
+
+	for dst, sources := range m.Incoming {
+		nodes[dst] = true
+
+		for _, src := range sources {
+			nodes[src] = true
+		}
+	}
+
+	m.Nodes = slices.Sorted(maps.Keys(nodes))
+	m.Suspicion = m.blame()
+	m.Partitions = m.partitions()
+}
+
+func (s *Server) fetchLocalMesh(ctx context.Context, podIP string) (*LocalMesh, error) {
+	ctx, cancel := context.WithTimeout(ctx, meshRequestTimeout)
+	defer cancel()
+
+	// the http listener is always started, also when TLS is used
+	url := "http://" + net.JoinHostPort(podIP, s.meshPort) + "/mesh/local"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.meshClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	local := LocalMesh{}
+	if err := json.NewDecoder(resp.Body).Decode(&local); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", url, err)
+	}
+
+	return &local, nil
+}
+
+// WriteTable writes the matrix as a plain-text table, with one row per source
+// node and one column per destination node. A cell holds the latency of a
+// successful check, ERR for a failed one, and - if there is no result.
+func (m *Mesh) WriteTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "SRC \\ DST\t"+strings.Join(m.Nodes, "\t"))
+
+	for _, src := range m.Nodes {
+		row := []string{src}
+
+		for _, dst := range m.Nodes {
+			row = append(row, m.cell(src, dst))
+		}
+
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	_ = tw.Flush()
+
+	if len(m.Partitions.Groups) > 1 {
+		fmt.Fprintf(w, "\nthe nodes are split in %d partitions:\n", len(m.Partitions.Groups))
+
+		for id, group := range m.Partitions.Groups {
+			fmt.Fprintf(w, "%d: %s\n", id, strings.Join(group, ", "))
+		}
+	}
+
+	if len(m.Partitions.Unknown) > 0 {
+		fmt.Fprintf(w, "\nnodes in an unknown partition: %s\n", strings.Join(m.Partitions.Unknown, ", "))
+	}
+
+	if len(m.Errors) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "\nkubenurses which couldn't be queried:")
+
+	for _, pod := range slices.Sorted(maps.Keys(m.Errors)) {
+		fmt.Fprintf(w, "%s: %s\n", pod, m.Errors[pod])
+	}
+}
+
+func (m *Mesh) cell(src, dst string) string {
+	pr, ok := m.Matrix[src][dst]
+
+	switch {
+	case !ok:
+		return "-"
+	case pr.Status != "ok":
+		return "ERR"
+	default:
+		return time.Duration(pr.LatencySeconds * float64(time.Second)).Round(100 * time.Microsecond).String()
+	}
+}
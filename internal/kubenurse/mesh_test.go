@@ -0,0 +1,125 @@
+package kubenurse
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/postfinance/kubenurse/internal/config"
+	"github.com/postfinance/kubenurse/internal/servicecheck"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMesh(t *testing.T) {
+	r := require.New(t)
+
+	var queries atomic.Int64
+
+	// the /mesh/local endpoint of the other kubenurse, running on node-b
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		queries.Add(1)
+		_ = json.NewEncoder(w).Encode(LocalMesh{
+			Node: "node-b",
+			Paths: map[string]servicecheck.PathResult{
+				"node-a": {Status: "ok", LatencySeconds: 0.0012},
+				"node-c": {Status: "connection refused"},
+			},
+		})
+	}))
+	defer local.Close()
+
+	newPod := func(name, node, ip string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-system", Labels: map[string]string{"app": "kubenurse"}},
+			Spec:       v1.PodSpec{NodeName: node},
+			Status:     v1.PodStatus{PodIP: ip, Phase: v1.PodRunning},
+		}
+	}
+
+	fakeClient := fake.NewFakeClient(
+		newPod("kubenurse-b", "node-b", "127.0.0.1"),
+		newPod("kubenurse-c", "node-c", "127.0.0.2"), // nothing listens there
+	)
+
+	t.Setenv("KUBENURSE_NAMESPACE", "kube-system")
+	t.Setenv("KUBENURSE_NEIGHBOUR_FILTER", "app=kubenurse")
+
+	cfg, err := config.Load("")
+	r.NoError(err)
+
+	kubenurse, err := New(fakeClient, cfg)
+	r.NoError(err)
+
+	kubenurse.meshPort = strconv.Itoa(local.Listener.Addr().(*net.TCPAddr).Port)
+
+	ts := httptest.NewServer(kubenurse.http.Handler)
+	defer ts.Close()
+
+	// the collection isn't cancelled with the request which started it, the
+	// mesh is cached for the next requests
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	kubenurse.meshHandler()(httptest.NewRecorder(), httptest.NewRequestWithContext(ctx, http.MethodGet, "/mesh", http.NoBody))
+
+	resp, err := http.Get(ts.URL + "/mesh")
+	r.NoError(err)
+
+	defer resp.Body.Close()
+
+	mesh := Mesh{}
+	r.NoError(json.NewDecoder(resp.Body).Decode(&mesh))
+
+	r.Equal([]string{"node-a", "node-b", "node-c"}, mesh.Nodes)
+	r.InDelta(0.0012, mesh.Matrix["node-b"]["node-a"].LatencySeconds, 1e-9)
+	r.Contains(mesh.Errors, "kubenurse-c")
+
+	var sb strings.Builder
+
+	mesh.WriteTable(&sb)
+
+	lines := strings.Split(sb.String(), "\n")
+	r.Equal("SRC \\ DST  node-a  node-b  node-c", strings.TrimSpace(lines[0]))
+	r.Equal("node-b     1.2ms   -       ERR", strings.TrimSpace(lines[2]))
+	r.Contains(sb.String(), "nodes in an unknown partition: node-c")
+
+	// the mesh is cached for the analysis interval
+	resp, err = http.Get(ts.URL + "/mesh?format=text")
+	r.NoError(err)
+
+	defer resp.Body.Close()
+
+	r.Equal(http.StatusOK, resp.StatusCode)
+	r.Equal(int64(1), queries.Load())
+}
+
+func TestAnalysisLeader(t *testing.T) {
+	pod := func(name string, phase v1.PodPhase, deleted bool) v1.Pod {
+		p := v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     v1.PodStatus{PodIP: "10.0.0.1", Phase: phase},
+		}
+
+		if deleted {
+			p.DeletionTimestamp = &metav1.Time{}
+		}
+
+		return p
+	}
+
+	require.Equal(t, "kubenurse-c", analysisLeader([]v1.Pod{
+		pod("kubenurse-d", v1.PodRunning, false),
+		pod("kubenurse-a", v1.PodPending, false),
+		pod("kubenurse-b", v1.PodRunning, true),
+		pod("kubenurse-c", v1.PodRunning, false),
+	}))
+	require.Empty(t, analysisLeader(nil))
+}
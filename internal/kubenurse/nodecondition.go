@@ -0,0 +1,216 @@
+package kubenurse
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/postfinance/kubenurse/internal/config"
+	"github.com/postfinance/kubenurse/internal/servicecheck"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// NetworkHealthyCondition is the condition which kubenurse publishes on
+	// its node, similar to the ones of the node-problem-detector.
+	NetworkHealthyCondition v1.NodeConditionType = "KubenurseNetworkHealthy"
+
+	// nodeConditionHeartbeat is how often the condition is patched when its
+	// status doesn't change, so that a stale condition can be recognized.
+	nodeConditionHeartbeat = time.Minute
+)
+
+// networkHealth is the summary of the local checks of a single run.
+type networkHealth struct {
+	healthy bool
+	reason  string
+	message string
+}
+
+// summarizeNetworkHealth tells whether the API server is reachable, the DNS
+// works and the paths to the neighbours are healthy, from the results of a
+// run. The skipped checks are ignored. The reason is the most important
+// problem, in the order in which they are checked, and the message lists all
+// of them.
+func summarizeNetworkHealth(results map[string]servicecheck.Result, maxPathFailureRatio float64) networkHealth {
+	status := func(name string) (servicecheck.Status, bool) {
+		res, ok := results[name]
+		return res.Status, ok && res.Status != servicecheck.StatusSkipped
+	}
+
+	var problems, reasons []string
+
+	// the API server is unreachable if all of its checks fail
+	apiChecked, apiOK := false, false
+
+	for _, name := range []string{servicecheck.APIServerDirect, servicecheck.APIServerDNS} {
+		if s, ok := status(name); ok {
+			apiChecked = true
+			apiOK = apiOK || s == servicecheck.StatusOK
+		}
+	}
+
+	if apiChecked && !apiOK {
+		reasons = append(reasons, "APIServerUnreachable")
+		problems = append(problems, "the API server is unreachable")
+	}
+
+	// the DNS is broken if the servers cannot be listed or none of them answers
+	dnsServers, dnsFailed := 0, 0
+
+	for name := range results {
+		if !strings.HasPrefix(name, "dns_") || name == servicecheck.DNSState {
+			continue
+		}
+
+		if s, ok := status(name); ok {
+			dnsServers++
+
+			if s != servicecheck.StatusOK {
+				dnsFailed++
+			}
+		}
+	}
+
+	if s, ok := status(servicecheck.DNSState); ok && s != servicecheck.StatusOK || dnsServers > 0 && dnsFailed == dnsServers {
+		reasons = append(reasons, "DNSFailing")
+		problems = append(problems, "no DNS server answers")
+	}
+
+	paths, pathsFailed := 0, 0
+
+	for name := range results {
+		if !strings.HasPrefix(name, "path_") {
+			continue
+		}
+
+		if s, ok := status(name); ok {
+			paths++
+
+			if s != servicecheck.StatusOK {
+				pathsFailed++
+			}
+		}
+	}
+
+	if paths > 0 && float64(pathsFailed)/float64(paths) > maxPathFailureRatio {
+		reasons = append(reasons, "NeighbourPathsFailing")
+		problems = append(problems, fmt.Sprintf("%d of %d neighbour paths fail", pathsFailed, paths))
+	}
+
+	if len(problems) == 0 {
+		return networkHealth{healthy: true, reason: "NetworkHealthy", message: "the network checks of kubenurse succeed"}
+	}
+
+	for i := range problems {
+		problems[i] = reasons[i] + ": " + problems[i]
+	}
+
+	return networkHealth{reason: reasons[0], message: strings.Join(problems, ", ")}
+}
+
+// nodeConditionPublisher patches the NetworkHealthyCondition of the local
+// node. The status only changes after a number of consecutive runs with the
+// new status, to avoid flapping.
+type nodeConditionPublisher struct {
+	client client.Client
+
+	// healthy is the health of the last run, and streak the number of
+	// consecutive runs with the same health
+	healthy bool
+	streak  int
+
+	// status is the published status, it is empty until a threshold is
+	// reached, and published the reason and message which go with it
+	status    v1.ConditionStatus
+	published networkHealth
+	lastPatch time.Time
+}
+
+// observe records the health of a run, and patches the condition of the node
+// when it changes or the heartbeat is due.
+func (p *nodeConditionPublisher) observe(ctx context.Context, nodeName string, results map[string]servicecheck.Result,
+	cfg *config.NodeCondition) {
+	health := summarizeNetworkHealth(results, cfg.MaxPathFailureRatio)
+
+	if p.streak > 0 && health.healthy == p.healthy {
+		p.streak++
+	} else {
+		p.healthy, p.streak = health.healthy, 1
+	}
+
+	status := p.status
+
+	switch {
+	case health.healthy && p.streak >= cfg.SuccessThreshold:
+		status = v1.ConditionTrue
+	case !health.healthy && p.streak >= cfg.FailureThreshold:
+		status = v1.ConditionFalse
+	}
+
+	if status == "" {
+		return
+	}
+
+	// while the health differs from the status, i.e. before a threshold is
+	// reached, the published reason and message are kept
+	published := p.published
+	if (status == v1.ConditionTrue) == health.healthy {
+		published = health
+	}
+
+	if status == p.status && published == p.published && time.Since(p.lastPatch) < nodeConditionHeartbeat {
+		return
+	}
+
+	if err := p.patch(ctx, nodeName, status, published); err != nil {
+		slog.Error("cannot update the condition of the node", "node", nodeName, "condition", NetworkHealthyCondition, "err", err)
+		return // retried on the next run
+	}
+
+	if status != p.status {
+		slog.Info("updated the condition of the node", "node", nodeName, "condition", NetworkHealthyCondition,
+			"status", status, "reason", published.reason)
+	}
+
+	p.status, p.published, p.lastPatch = status, published, time.Now()
+}
+
+func (p *nodeConditionPublisher) patch(ctx context.Context, nodeName string, status v1.ConditionStatus,
+	health networkHealth) error {
+	node := v1.Node{}
+	if err := p.client.Get(ctx, client.ObjectKey{Name: nodeName}, &node); err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	cond := v1.NodeCondition{
+		Type:               NetworkHealthyCondition,
+		Status:             status,
+		Reason:             health.reason,
+		Message:            health.message,
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+	}
+
+	patch := client.StrategicMergeFrom(node.DeepCopy())
+
+	i := slices.IndexFunc(node.Status.Conditions, func(c v1.NodeCondition) bool { return c.Type == NetworkHealthyCondition })
+
+	switch {
+	case i < 0:
+		node.Status.Conditions = append(node.Status.Conditions, cond)
+	case node.Status.Conditions[i].Status == status:
+		cond.LastTransitionTime = node.Status.Conditions[i].LastTransitionTime
+		node.Status.Conditions[i] = cond
+	default:
+		node.Status.Conditions[i] = cond
+	}
+
+	return p.client.Status().Patch(ctx, &node, patch)
+}
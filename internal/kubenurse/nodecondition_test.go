@@ -0,0 +1,107 @@
+package kubenurse
+
+import (
+	"context"
+	"testing"
+
+	"github.com/postfinance/kubenurse/internal/config"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSummarizeNetworkHealth(t *testing.T) {
+	for name, tc := range map[string]struct {
+		results map[string]string
+		reason  string
+		message string
+	}{
+		"healthy": {
+			results: map[string]string{"api_server_direct": "ok", "api_server_dns": "timeout", "dns_state": "ok",
+				"dns_10.0.0.10": "ok", "dns_10.0.0.11": "SERVFAIL", "path_node-b": "ok", "path_node-c": "refused"},
+			reason: "NetworkHealthy",
+		},
+		"skipped checks": {
+			results: map[string]string{"api_server_direct": "skipped", "api_server_dns": "skipped", "neighbourhood_state": "skipped"},
+			reason:  "NetworkHealthy",
+		},
+		"everything broken": {
+			results: map[string]string{"api_server_direct": "timeout", "api_server_dns": "skipped", "dns_state": "ok",
+				"dns_10.0.0.10": "timeout", "path_node-b": "refused", "path_node-c": "refused", "path_node-d": "ok"},
+			reason: "APIServerUnreachable",
+			message: "APIServerUnreachable: the API server is unreachable, DNSFailing: no DNS server answers, " +
+				"NeighbourPathsFailing: 2 of 3 neighbour paths fail",
+		},
+		"dns servers cannot be listed": {
+			results: map[string]string{"dns_state": "list dns endpointslices: forbidden"},
+			reason:  "DNSFailing",
+			message: "DNSFailing: no DNS server answers",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			health := summarizeNetworkHealth(checkResults(tc.results), 0.5)
+
+			require.Equal(t, tc.reason, health.reason)
+			require.Equal(t, tc.reason == "NetworkHealthy", health.healthy)
+
+			if tc.message != "" {
+				require.Equal(t, tc.message, health.message)
+			}
+		})
+	}
+}
+
+func TestNodeConditionPublisher(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}}
+	fakeClient := fake.NewClientBuilder().WithObjects(node).WithStatusSubresource(node).Build()
+
+	publisher := nodeConditionPublisher{client: fakeClient}
+	cfg := config.NodeCondition{FailureThreshold: 3, SuccessThreshold: 2, MaxPathFailureRatio: 0.5}
+
+	healthy := checkResults(map[string]string{"api_server_direct": "ok"})
+	unhealthy := checkResults(map[string]string{"api_server_direct": "timeout"})
+
+	condition := func() *v1.NodeCondition {
+		n := v1.Node{}
+		r.NoError(fakeClient.Get(ctx, client.ObjectKeyFromObject(node), &n))
+
+		for i := range n.Status.Conditions {
+			if n.Status.Conditions[i].Type == NetworkHealthyCondition {
+				return &n.Status.Conditions[i]
+			}
+		}
+
+		return nil
+	}
+
+	publisher.observe(ctx, "node-a", healthy, &cfg)
+	r.Nil(condition(), "the status is unknown until the success threshold is reached")
+
+	publisher.observe(ctx, "node-a", healthy, &cfg)
+	r.Equal(v1.ConditionTrue, condition().Status)
+
+	// a short failure doesn't flip the condition
+	publisher.observe(ctx, "node-a", unhealthy, &cfg)
+	publisher.observe(ctx, "node-a", unhealthy, &cfg)
+	publisher.observe(ctx, "node-a", healthy, &cfg)
+	publisher.observe(ctx, "node-a", unhealthy, &cfg)
+	publisher.observe(ctx, "node-a", unhealthy, &cfg)
+	r.Equal(v1.ConditionTrue, condition().Status)
+	r.Equal("NetworkHealthy", condition().Reason)
+
+	publisher.observe(ctx, "node-a", unhealthy, &cfg)
+	r.Equal(v1.ConditionFalse, condition().Status)
+	r.Equal("APIServerUnreachable", condition().Reason)
+	r.Equal("APIServerUnreachable: the API server is unreachable", condition().Message)
+
+	publisher.observe(ctx, "node-a", healthy, &cfg)
+	r.Equal(v1.ConditionFalse, condition().Status)
+
+	publisher.observe(ctx, "node-a", healthy, &cfg)
+	r.Equal(v1.ConditionTrue, condition().Status)
+}
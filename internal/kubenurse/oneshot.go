@@ -0,0 +1,111 @@
+package kubenurse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/postfinance/kubenurse/internal/servicecheck"
+)
+
+// CheckOptions configures RunChecks, the one-shot mode of kubenurse.
+type CheckOptions struct {
+	// Runs is the number of times the checks are run, at least once
+	Runs int
+	// Interval is the pause between two runs
+	Interval time.Duration
+	// JSON writes the report as JSON instead of a table
+	JSON bool
+}
+
+// checkReport is the JSON report of RunChecks.
+type checkReport struct {
+	OK bool `json:"ok"`
+	// Failed are the checks which failed during at least one run
+	Failed []string                 `json:"failed"`
+	Runs   []*servicecheck.Snapshot `json:"runs"`
+}
+
+// RunChecks runs the checks of chk once or several times without serving
+// anything, and writes a report to w. It returns false if any check failed
+// during any of the runs.
+func RunChecks(ctx context.Context, chk *servicecheck.Checker, opts CheckOptions, w io.Writer) (bool, error) {
+	report := checkReport{Failed: []string{}}
+
+	for i := range max(opts.Runs, 1) {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return false, ctx.Err()
+			case <-time.After(opts.Interval):
+			}
+		}
+
+		chk.Run(ctx)
+
+		snapshot := chk.LastResults()
+		report.Runs = append(report.Runs, snapshot)
+
+		for name, res := range snapshot.Checks {
+			if res.Status == servicecheck.StatusError && !slices.Contains(report.Failed, name) {
+				report.Failed = append(report.Failed, name)
+			}
+		}
+
+		if !opts.JSON {
+			if err := writeRunTable(w, i+1, snapshot); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	slices.Sort(report.Failed)
+	report.OK = len(report.Failed) == 0
+
+	if opts.JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", " ")
+
+		return report.OK, enc.Encode(report)
+	}
+
+	summary := fmt.Sprintf("all checks succeeded in %d run(s)\n", len(report.Runs))
+	if !report.OK {
+		summary = fmt.Sprintf("%d check(s) failed: %s\n", len(report.Failed), strings.Join(report.Failed, ", "))
+	}
+
+	_, err := io.WriteString(w, summary)
+
+	return report.OK, err
+}
+
+// writeRunTable writes the results of a run as a table sorted by check name.
+func writeRunTable(w io.Writer, run int, snapshot *servicecheck.Snapshot) error {
+	names := make([]string, 0, len(snapshot.Checks))
+	for name := range snapshot.Checks {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "run %d at %s\n", run, snapshot.Timestamp.Format(time.RFC3339))
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tLATENCY\tMESSAGE")
+
+	for _, name := range names {
+		res := snapshot.Checks[name]
+		latency := time.Duration(res.LatencySeconds * float64(time.Second)).Round(time.Millisecond)
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, res.Status, latency, res.Message)
+	}
+
+	fmt.Fprintln(tw)
+
+	return tw.Flush()
+}
@@ -0,0 +1,75 @@
+package kubenurse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/postfinance/kubenurse/internal/config"
+	"github.com/postfinance/kubenurse/internal/servicecheck"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRunChecks(t *testing.T) {
+	r := require.New(t)
+
+	var unhealthy atomic.Bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if unhealthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	cfg, err := config.Load("")
+	r.NoError(err)
+
+	cfg.Checks.APIServerDirect = false
+	cfg.Checks.APIServerDNS = false
+	cfg.Checks.MeIngress = false
+	cfg.Checks.Neighbourhood = false
+	cfg.Checker.ServiceURL = server.URL
+
+	chk, err := NewChecker(fake.NewFakeClient(), cfg)
+	r.NoError(err)
+
+	var out bytes.Buffer
+
+	ok, err := RunChecks(context.Background(), chk, CheckOptions{Runs: 2}, &out)
+	r.NoError(err)
+	r.True(ok)
+	r.Contains(out.String(), "run 2 at ")
+	r.Regexp(`me_service\s+ok`, out.String())
+	r.Contains(out.String(), "all checks succeeded in 2 run(s)")
+
+	unhealthy.Store(true)
+
+	out.Reset()
+
+	ok, err = RunChecks(context.Background(), chk, CheckOptions{JSON: true}, &out)
+	r.NoError(err)
+	r.False(ok)
+
+	var report checkReport
+
+	r.NoError(json.Unmarshal(out.Bytes(), &report))
+	r.False(report.OK)
+	r.Equal([]string{"me_service"}, report.Failed)
+	r.Len(report.Runs, 1)
+	r.Equal(servicecheck.StatusError, report.Runs[0].Checks["me_service"].Status)
+	r.Equal(http.StatusServiceUnavailable, report.Runs[0].Checks["me_service"].HTTPCode)
+
+	// the runs stop with the context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = RunChecks(ctx, chk, CheckOptions{Runs: 2, Interval: time.Hour}, &out)
+	r.ErrorIs(err, context.Canceled)
+}
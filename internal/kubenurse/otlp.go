@@ -0,0 +1,63 @@
+package kubenurse
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+
+	"github.com/postfinance/kubenurse/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+)
+
+// otlpURL returns the url of the signal path, e.g. v1/traces, below the base
+// url of an OTLP/HTTP collector.
+func otlpURL(endpoint, signal string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	u.Path = path.Join("/", u.Path, signal)
+
+	return u.String(), nil
+}
+
+// podName returns the name of the kubenurse pod, which is the hostname unless
+// it is configured.
+func podName(cfg *config.Config) string {
+	if cfg.Resource.PodName != "" {
+		return cfg.Resource.PodName
+	}
+
+	hostname, _ := os.Hostname()
+
+	return hostname
+}
+
+// newResource describes the kubenurse pod in the exported traces and
+// metrics.
+func newResource(cfg *config.Config) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName("kubenurse"),
+		semconv.K8SPodName(podName(cfg)),
+		semconv.K8SNamespaceName(cfg.Checker.Namespace),
+	}
+
+	if cfg.Checker.NodeName != "" {
+		attrs = append(attrs, semconv.K8SNodeName(cfg.Checker.NodeName))
+	}
+
+	if cfg.Resource.ClusterName != "" {
+		attrs = append(attrs, semconv.K8SClusterName(cfg.Resource.ClusterName))
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, attrs...))
+	if err != nil {
+		return nil, fmt.Errorf("create the otlp resource: %w", err)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,351 @@
+package kubenurse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/postfinance/kubenurse/internal/config"
+	"github.com/postfinance/kubenurse/internal/servicecheck"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// newMeterProvider creates the provider which periodically exports the
+// metrics of /metrics to the OTLP/HTTP collector of cfg.
+func newMeterProvider(cfg *config.Config) (*sdkmetric.MeterProvider, error) {
+	endpoint, err := otlpURL(cfg.Metrics.OTLP.Endpoint, "v1/metrics")
+	if err != nil {
+		return nil, fmt.Errorf("parse the otlp metrics endpoint: %w", err)
+	}
+
+	exporter, err := otlpmetrichttp.New(context.Background(), otlpmetrichttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("create the otlp metrics exporter: %w", err)
+	}
+
+	res, err := newResource(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := sdkmetric.NewPeriodicReader(exporter,
+		sdkmetric.WithInterval(cfg.Metrics.OTLP.Interval.Duration),
+		sdkmetric.WithProducer(&metricsProducer{start: time.Now()}),
+	)
+
+	return sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithResource(res)), nil
+}
+
+// metricsProducer converts the metrics of the metrics package, as exposed on
+// /metrics, to OpenTelemetry metrics: the histograms with either le or
+// vmrange buckets to histograms, the _total counters to monotonic sums, and
+// the other metrics to gauges.
+type metricsProducer struct {
+	// start is the start time of the cumulative sums and histograms
+	start time.Time
+}
+
+// Produce implements sdkmetric.Producer.
+func (p *metricsProducer) Produce(context.Context) ([]metricdata.ScopeMetrics, error) {
+	var buf bytes.Buffer
+
+	metrics.GetDefaultSet().WritePrometheus(&buf)
+
+	samples, err := parseSamples(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return []metricdata.ScopeMetrics{{
+		Scope:   instrumentation.Scope{Name: servicecheck.TracerName},
+		Metrics: convertSamples(samples, p.start, time.Now()),
+	}}, nil
+}
+
+// sample is a line of the Prometheus text format, e.g.
+// kubenurse_errors_total{type="me_ingress",event="connect_done"} 3
+type sample struct {
+	name   string
+	labels []label
+	value  float64
+}
+
+type label struct {
+	name, value string
+}
+
+// parseSamples parses the Prometheus text format written by the metrics
+// package, the comments are ignored.
+func parseSamples(r io.Reader) ([]sample, error) {
+	var samples []sample
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		s, err := parseSample(line)
+		if err != nil {
+			return nil, err
+		}
+
+		samples = append(samples, s)
+	}
+
+	return samples, scanner.Err()
+}
+
+func parseSample(line string) (sample, error) {
+	var s sample
+
+	i := strings.IndexAny(line, "{ ")
+	if i <= 0 {
+		return s, fmt.Errorf("invalid sample %q", line)
+	}
+
+	s.name, line = line[:i], line[i:]
+
+	if strings.HasPrefix(line, "{") {
+		line = line[1:]
+
+		for !strings.HasPrefix(line, "}") {
+			name, rest, ok := strings.Cut(line, "=")
+			if !ok {
+				return s, fmt.Errorf("invalid labels in sample of %s", s.name)
+			}
+
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return s, fmt.Errorf("invalid value of label %s in sample of %s: %w", name, s.name, err)
+			}
+
+			value, _ := strconv.Unquote(quoted) // valid, as it was found by QuotedPrefix
+
+			s.labels = append(s.labels, label{name: strings.TrimSpace(name), value: value})
+			line = strings.TrimPrefix(rest[len(quoted):], ",")
+		}
+
+		line = line[1:]
+	}
+
+	fields := strings.Fields(line) // the value, and an optional timestamp
+	if len(fields) == 0 {
+		return s, fmt.Errorf("missing value in sample of %s", s.name)
+	}
+
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return s, fmt.Errorf("invalid value in sample of %s: %w", s.name, err)
+	}
+
+	s.value = value
+
+	return s, nil
+}
+
+// seriesKey identifies the series of a metric.
+type seriesKey struct {
+	name  string
+	attrs attribute.Distinct
+}
+
+// histogramPoint accumulates the samples of a histogram series.
+type histogramPoint struct {
+	attrs attribute.Set
+	// buckets are the counts by upper bound, which are cumulative for the le
+	// buckets of the Prometheus histograms
+	buckets    map[float64]float64
+	cumulative bool
+	sum        float64
+	count      float64
+}
+
+// convertSamples converts the samples to OpenTelemetry metrics, sorted by
+// name.
+func convertSamples(samples []sample, start, now time.Time) []metricdata.Metrics {
+	// the histograms are found by their buckets first, as their _sum and
+	// _count samples cannot be told from other metrics otherwise
+	histograms := make(map[string]bool)
+
+	for _, s := range samples {
+		if base, ok := strings.CutSuffix(s.name, "_bucket"); ok && bucketLabel(s) != "" {
+			histograms[base] = true
+		}
+	}
+
+	var (
+		gauges = make(map[string][]metricdata.DataPoint[float64])
+		sums   = make(map[string][]metricdata.DataPoint[float64])
+		points = make(map[string][]*histogramPoint)
+		series = make(map[seriesKey]*histogramPoint)
+	)
+
+	for _, s := range samples {
+		if base, suffix, ok := histogramSample(s.name, histograms); ok {
+			bucket := bucketLabel(s)
+			attrs := attributes(s.labels, bucket)
+			key := seriesKey{name: base, attrs: attrs.Equivalent()}
+
+			if series[key] == nil {
+				series[key] = &histogramPoint{attrs: attrs, buckets: make(map[float64]float64)}
+				points[base] = append(points[base], series[key])
+			}
+
+			series[key].add(s, suffix, bucket)
+
+			continue
+		}
+
+		dp := metricdata.DataPoint[float64]{Attributes: attributes(s.labels, ""), Time: now, Value: s.value}
+
+		if strings.HasSuffix(s.name, "_total") {
+			dp.StartTime = start
+			sums[s.name] = append(sums[s.name], dp)
+		} else {
+			gauges[s.name] = append(gauges[s.name], dp)
+		}
+	}
+
+	result := make([]metricdata.Metrics, 0, len(gauges)+len(sums)+len(points))
+
+	for name, dps := range gauges {
+		result = append(result, metricdata.Metrics{Name: name, Data: metricdata.Gauge[float64]{DataPoints: dps}})
+	}
+
+	for name, dps := range sums {
+		result = append(result, metricdata.Metrics{Name: name, Data: metricdata.Sum[float64]{
+			DataPoints:  dps,
+			Temporality: metricdata.CumulativeTemporality,
+			IsMonotonic: true,
+		}})
+	}
+
+	for name, hps := range points {
+		h := metricdata.Histogram[float64]{Temporality: metricdata.CumulativeTemporality}
+		for _, hp := range hps {
+			h.DataPoints = append(h.DataPoints, hp.dataPoint(start, now))
+		}
+
+		result = append(result, metricdata.Metrics{Name: name, Data: h})
+	}
+
+	slices.SortFunc(result, func(a, b metricdata.Metrics) int { return strings.Compare(a.Name, b.Name) })
+
+	return result
+}
+
+// histogramSample returns the histogram and the suffix of a sample of one of
+// the histograms.
+func histogramSample(name string, histograms map[string]bool) (base, suffix string, ok bool) {
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if base, found := strings.CutSuffix(name, suffix); found && histograms[base] {
+			return base, suffix, true
+		}
+	}
+
+	return "", "", false
+}
+
+// bucketLabel returns the name of the le or vmrange label of a sample, or
+// an empty string.
+func bucketLabel(s sample) string {
+	for _, l := range s.labels {
+		if l.name == "le" || l.name == "vmrange" {
+			return l.name
+		}
+	}
+
+	return ""
+}
+
+// attributes converts the labels to attributes, without the skipped one.
+func attributes(labels []label, skip string) attribute.Set {
+	kvs := make([]attribute.KeyValue, 0, len(labels))
+
+	for _, l := range labels {
+		if l.name != skip {
+			kvs = append(kvs, attribute.String(l.name, l.value))
+		}
+	}
+
+	return attribute.NewSet(kvs...)
+}
+
+func (p *histogramPoint) add(s sample, suffix, bucket string) {
+	switch suffix {
+	case "_sum":
+		p.sum = s.value
+	case "_count":
+		p.count = s.value
+	default:
+		for _, l := range s.labels {
+			if l.name != bucket {
+				continue
+			}
+
+			// a vmrange is e.g. 1.000e-03...1.136e-03, only its upper bound
+			// is kept as the empty ranges are omitted
+			bound := l.value
+			if _, upper, ok := strings.Cut(l.value, "..."); ok {
+				bound = upper
+			}
+
+			if upper, err := strconv.ParseFloat(bound, 64); err == nil {
+				p.buckets[upper] += s.value
+			}
+		}
+
+		p.cumulative = bucket == "le"
+	}
+}
+
+// dataPoint returns the explicit bucket histogram of the point, whose bounds
+// are the finite upper bounds of the buckets.
+func (p *histogramPoint) dataPoint(start, now time.Time) metricdata.HistogramDataPoint[float64] {
+	dp := metricdata.HistogramDataPoint[float64]{
+		Attributes: p.attrs,
+		StartTime:  start,
+		Time:       now,
+		Count:      uint64(p.count),
+		Sum:        p.sum,
+	}
+
+	var (
+		previous float64
+		inf      uint64
+	)
+
+	for _, upper := range slices.Sorted(maps.Keys(p.buckets)) {
+		count := p.buckets[upper]
+		if p.cumulative {
+			count, previous = count-previous, count
+		}
+
+		if math.IsInf(upper, 1) {
+			inf += uint64(count)
+			continue
+		}
+
+		dp.Bounds = append(dp.Bounds, upper)
+		dp.BucketCounts = append(dp.BucketCounts, uint64(count))
+	}
+
+	dp.BucketCounts = append(dp.BucketCounts, inf)
+
+	return dp
+}
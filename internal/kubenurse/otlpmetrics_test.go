@@ -0,0 +1,170 @@
+package kubenurse
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/postfinance/kubenurse/internal/config"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/proto"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseSamples(t *testing.T) {
+	r := require.New(t)
+
+	samples, err := parseSamples(strings.NewReader(`# TYPE kubenurse_errors_total counter
+kubenurse_errors_total{type="me_ingress",event="connect_done"} 3
+kubenurse_neighbourhood_incoming_checks 4.5
+
+kubenurse_check_status{msg="a \"quoted\", value\\"} 0
+`))
+	r.NoError(err)
+	r.Equal([]sample{
+		{name: "kubenurse_errors_total", labels: []label{{"type", "me_ingress"}, {"event", "connect_done"}}, value: 3},
+		{name: "kubenurse_neighbourhood_incoming_checks", value: 4.5},
+		{name: "kubenurse_check_status", labels: []label{{"msg", `a "quoted", value\`}}, value: 0},
+	}, samples)
+
+	for _, invalid := range []string{
+		"kubenurse_errors_total",
+		`kubenurse_errors_total{type="me_ingress" 3`,
+		`kubenurse_errors_total{type=me_ingress} 3`,
+		"kubenurse_errors_total NaN?",
+	} {
+		_, err := parseSamples(strings.NewReader(invalid))
+		r.Error(err, invalid)
+	}
+}
+
+func TestConvertSamples(t *testing.T) {
+	r := require.New(t)
+
+	samples, err := parseSamples(strings.NewReader(`kubenurse_errors_total{type="me_ingress"} 3
+kubenurse_neighbourhood_incoming_checks 4
+kubenurse_httpclient_request_duration_seconds_bucket{type="me_ingress",le="0.1"} 1
+kubenurse_httpclient_request_duration_seconds_bucket{type="me_ingress",le="1"} 3
+kubenurse_httpclient_request_duration_seconds_bucket{type="me_ingress",le="+Inf"} 4
+kubenurse_httpclient_request_duration_seconds_sum{type="me_ingress"} 7.5
+kubenurse_httpclient_request_duration_seconds_count{type="me_ingress"} 4
+kubenurse_udp_rtt_seconds_bucket{type="udp_a",vmrange="1.000e-03...1.136e-03"} 2
+kubenurse_udp_rtt_seconds_bucket{type="udp_a",vmrange="4.642e-02...5.275e-02"} 1
+kubenurse_udp_rtt_seconds_sum{type="udp_a"} 0.05
+kubenurse_udp_rtt_seconds_count{type="udp_a"} 3
+`))
+	r.NoError(err)
+
+	start, now := time.Unix(100, 0), time.Unix(200, 0)
+	converted := convertSamples(samples, start, now)
+
+	r.Len(converted, 4)
+	r.Equal("kubenurse_errors_total", converted[0].Name)
+	r.Equal(metricdata.Sum[float64]{
+		DataPoints: []metricdata.DataPoint[float64]{{
+			Attributes: attribute.NewSet(attribute.String("type", "me_ingress")), StartTime: start, Time: now, Value: 3,
+		}},
+		Temporality: metricdata.CumulativeTemporality,
+		IsMonotonic: true,
+	}, converted[0].Data)
+
+	r.Equal("kubenurse_httpclient_request_duration_seconds", converted[1].Name)
+	r.Equal(metricdata.Histogram[float64]{
+		DataPoints: []metricdata.HistogramDataPoint[float64]{{
+			Attributes:   attribute.NewSet(attribute.String("type", "me_ingress")),
+			StartTime:    start,
+			Time:         now,
+			Count:        4,
+			Bounds:       []float64{0.1, 1},
+			BucketCounts: []uint64{1, 2, 1},
+			Sum:          7.5,
+		}},
+		Temporality: metricdata.CumulativeTemporality,
+	}, converted[1].Data)
+
+	r.Equal("kubenurse_neighbourhood_incoming_checks", converted[2].Name)
+	r.IsType(metricdata.Gauge[float64]{}, converted[2].Data)
+
+	r.Equal("kubenurse_udp_rtt_seconds", converted[3].Name)
+	udp := converted[3].Data.(metricdata.Histogram[float64]).DataPoints[0]
+	r.Equal([]float64{1.136e-03, 5.275e-02}, udp.Bounds)
+	r.Equal([]uint64{2, 1, 0}, udp.BucketCounts)
+	r.Equal(uint64(3), udp.Count)
+}
+
+func TestOTLPMetrics(t *testing.T) {
+	r := require.New(t)
+
+	var (
+		mu       sync.Mutex
+		requests []*colmetricpb.ExportMetricsServiceRequest
+	)
+
+	otlp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		var export colmetricpb.ExportMetricsServiceRequest
+		if req.URL.Path != "/v1/metrics" || proto.Unmarshal(body, &export) != nil {
+			http.Error(w, "invalid export", http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests = append(requests, &export)
+	}))
+	defer otlp.Close()
+
+	t.Setenv("KUBENURSE_OTLP_METRICS_ENDPOINT", otlp.URL)
+	t.Setenv("KUBENURSE_CLUSTER_NAME", "test-cluster")
+	t.Setenv("KUBENURSE_NODE_NAME", "node-a")
+
+	cfg, err := config.Load("")
+	r.NoError(err)
+
+	kubenurse, err := New(fake.NewFakeClient(), cfg)
+	r.NoError(err)
+
+	metrics.GetOrCreateCounter(`kubenurse_otlp_test_total{type="me_ingress"}`).Add(2)
+
+	r.NoError(kubenurse.meterProvider.ForceFlush(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	r.NotEmpty(requests)
+
+	rm := requests[0].GetResourceMetrics()[0]
+	attrs := make(map[string]string)
+
+	for _, kv := range rm.GetResource().GetAttributes() {
+		attrs[kv.GetKey()] = kv.GetValue().GetStringValue()
+	}
+
+	r.Equal("kubenurse", attrs["service.name"])
+	r.Equal("node-a", attrs["k8s.node.name"])
+	r.Equal("test-cluster", attrs["k8s.cluster.name"])
+
+	var found bool
+
+	for _, m := range rm.GetScopeMetrics()[0].GetMetrics() {
+		if m.GetName() == "kubenurse_otlp_test_total" {
+			found = true
+
+			r.True(m.GetSum().GetIsMonotonic())
+			r.InDelta(2, m.GetSum().GetDataPoints()[0].GetAsDouble(), 0)
+		}
+	}
+
+	r.True(found, "the counter must be exported")
+}
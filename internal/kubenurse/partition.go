@@ -0,0 +1,184 @@
+package kubenurse
+
+import (
+	"cmp"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/postfinance/kubenurse/internal/util"
+)
+
+const (
+	partitionCount = "partition_count"
+	nodePartition  = "node_partition"
+)
+
+// Partitions are the groups of nodes which are connected by successful path
+// checks, in either direction. With a neighbour limit, every node only checks
+// some of the others, so two nodes are in the same partition if there is a
+// chain of successful checks between them.
+type Partitions struct {
+	// Groups are the sorted nodes of every partition, the largest first, and
+	// the index of a group is its partition ID
+	Groups [][]string `json:"groups"`
+	// OneWay are the failed path checks whose reverse check succeeded, i.e.
+	// the destination reaches the source, but not the other way around
+	OneWay []OneWayPath `json:"one_way,omitempty"`
+	// Unknown are the sorted nodes whose kubenurse couldn't be queried, and
+	// which no successful path check connects to the others. They aren't in
+	// any group, as they may as well be partitioned as have no results yet.
+	Unknown []string `json:"unknown,omitempty"`
+}
+
+// OneWayPath is a path from Src to Dst which only works from Dst to Src.
+type OneWayPath struct {
+	Src string `json:"src"`
+	Dst string `json:"dst"`
+}
+
+// partitions computes the connected components of the graph of the
+// successful path checks with a union-find.
+func (m *Mesh) partitions() Partitions {
+	paths := m.paths()
+
+	parent := make(map[string]string, len(m.Nodes))
+	for _, node := range m.Nodes {
+		parent[node] = node
+	}
+
+	var find func(string) string
+
+	find = func(n string) string {
+		if parent[n] != n {
+			parent[n] = find(parent[n])
+		}
+
+		return parent[n]
+	}
+
+	p := Partitions{}
+
+	for _, src := range slices.Sorted(maps.Keys(paths)) {
+		for _, dst := range slices.Sorted(maps.Keys(paths[src])) {
+			if paths[src][dst] {
+				parent[find(src)] = find(dst)
+				continue
+			}
+
+			if reverse, checked := paths[dst][src]; checked && reverse {
+				p.OneWay = append(p.OneWay, OneWayPath{Src: src, Dst: dst})
+			}
+		}
+	}
+
+	groups := make(map[string][]string)
+
+	for _, node := range m.Nodes { // sorted, and so are the groups
+		root := find(node)
+		groups[root] = append(groups[root], node)
+	}
+
+	for root, group := range groups {
+		if _, queried := m.Matrix[group[0]]; len(group) == 1 && !queried {
+			p.Unknown = append(p.Unknown, group[0])
+			delete(groups, root)
+		}
+	}
+
+	slices.Sort(p.Unknown)
+
+	p.Groups = slices.SortedFunc(maps.Values(groups), func(a, b []string) int {
+		return cmp.Or(cmp.Compare(len(b), len(a)), cmp.Compare(a[0], b[0]))
+	})
+
+	return p
+}
+
+// partitionTracker exports the partitions of the successive analyses, and
+// logs when the cluster splits or heals.
+type partitionTracker struct {
+	last *Partitions
+	// exported are the names of the exported per-node gauges
+	exported map[string]bool
+}
+
+func (t *partitionTracker) observe(p *Partitions) {
+	metrics.GetOrCreateGauge(util.MetricsNamespace+"_"+partitionCount, nil).Set(float64(len(p.Groups)))
+
+	seen := make(map[string]bool)
+
+	for id, group := range p.Groups {
+		for _, node := range group {
+			name := util.GenMetricsName(nodePartition, "node", node)
+			metrics.GetOrCreateGauge(name, nil).Set(float64(id))
+
+			seen[name] = true
+		}
+	}
+
+	// the nodes which left the cluster must not stay in a partition forever
+	for name := range t.exported {
+		if !seen[name] {
+			metrics.UnregisterMetric(name)
+		}
+	}
+
+	t.exported = seen
+
+	var (
+		wasSplit   bool
+		lastOneWay []OneWayPath
+	)
+
+	if t.last != nil {
+		wasSplit = len(t.last.Groups) > 1
+		lastOneWay = t.last.OneWay
+	}
+
+	for _, path := range p.OneWay {
+		if !slices.Contains(lastOneWay, path) {
+			slog.Warn("one-way path detected", "src", path.Src, "dst", path.Dst)
+		}
+	}
+
+	split := len(p.Groups) > 1
+
+	switch {
+	case split && !wasSplit:
+		slog.Warn("network partition detected", "partitions", len(p.Groups), "groups", groupsAttr(p.Groups))
+	case split && !slices.EqualFunc(p.Groups, t.last.Groups, slices.Equal):
+		slog.Warn("network partitions changed", "partitions", len(p.Groups), "groups", groupsAttr(p.Groups))
+	case !split && wasSplit:
+		slog.Info("network partition healed", "partitions", len(p.Groups))
+	}
+
+	t.last = p
+}
+
+// reset unregisters the exported gauges, and forgets the last partitions.
+func (t *partitionTracker) reset() {
+	if t.last == nil {
+		return
+	}
+
+	metrics.UnregisterMetric(util.MetricsNamespace + "_" + partitionCount)
+
+	for name := range t.exported {
+		metrics.UnregisterMetric(name)
+	}
+
+	t.last, t.exported = nil, nil
+}
+
+// groupsAttr returns the groups by partition ID, to log them.
+func groupsAttr(groups [][]string) map[string][]string {
+	attr := make(map[string][]string, len(groups))
+	for id, group := range groups {
+		attr[strconv.Itoa(id)] = group
+	}
+
+	return attr
+}
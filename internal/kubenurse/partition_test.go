@@ -0,0 +1,84 @@
+package kubenurse
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitions(t *testing.T) {
+	nodes := []string{"a", "b", "c", "d", "e"}
+	side := map[string]int{"a": 0, "b": 0, "c": 0, "d": 1, "e": 1}
+
+	t.Run("healthy", func(t *testing.T) {
+		m := Mesh{Matrix: fullMesh(nodes, func(_, _ string) bool { return false })}
+		m.complete()
+
+		require.Equal(t, [][]string{nodes}, m.Partitions.Groups)
+		require.Empty(t, m.Partitions.OneWay)
+	})
+
+	t.Run("split", func(t *testing.T) {
+		m := Mesh{Matrix: fullMesh(nodes, func(src, dst string) bool { return side[src] != side[dst] })}
+		m.complete()
+
+		require.Equal(t, [][]string{{"a", "b", "c"}, {"d", "e"}}, m.Partitions.Groups)
+		require.Empty(t, m.Partitions.OneWay)
+	})
+
+	t.Run("one-way", func(t *testing.T) {
+		// e reaches the others, but the others cannot reach e
+		m := Mesh{Matrix: fullMesh(nodes, func(_, dst string) bool { return dst == "e" })}
+		m.complete()
+
+		require.Equal(t, [][]string{nodes}, m.Partitions.Groups)
+		require.Equal(t, []OneWayPath{{"a", "e"}, {"b", "e"}, {"c", "e"}, {"d", "e"}}, m.Partitions.OneWay)
+	})
+
+	t.Run("isolated node which cannot be queried", func(t *testing.T) {
+		m := Mesh{Matrix: fullMesh(nodes, func(_, dst string) bool { return dst == "e" })}
+		delete(m.Matrix, "e")
+		m.complete()
+
+		// e is unknown rather than a partition of its own
+		require.Equal(t, [][]string{{"a", "b", "c", "d"}}, m.Partitions.Groups)
+		require.Equal(t, []string{"e"}, m.Partitions.Unknown)
+		require.Empty(t, m.Partitions.OneWay)
+	})
+
+	t.Run("reachable node which cannot be queried", func(t *testing.T) {
+		m := Mesh{Matrix: fullMesh(nodes, func(_, _ string) bool { return false })}
+		delete(m.Matrix, "e")
+		m.complete()
+
+		require.Equal(t, [][]string{nodes}, m.Partitions.Groups)
+		require.Empty(t, m.Partitions.Unknown)
+	})
+
+	t.Run("isolated node which can be queried", func(t *testing.T) {
+		m := Mesh{Matrix: fullMesh(nodes, func(src, dst string) bool { return src == "e" || dst == "e" })}
+		m.complete()
+
+		require.Equal(t, [][]string{{"a", "b", "c", "d"}, {"e"}}, m.Partitions.Groups)
+		require.Empty(t, m.Partitions.Unknown)
+	})
+}
+
+func TestPartitionTracker(t *testing.T) {
+	r := require.New(t)
+
+	var tracker partitionTracker
+
+	tracker.observe(&Partitions{Groups: [][]string{{"a", "b"}, {"c"}}})
+
+	r.InDelta(2, metrics.GetOrCreateGauge(`kubenurse_partition_count`, nil).Get(), 0)
+	r.InDelta(0, metrics.GetOrCreateGauge(`kubenurse_node_partition{node="a"}`, nil).Get(), 0)
+	r.InDelta(1, metrics.GetOrCreateGauge(`kubenurse_node_partition{node="c"}`, nil).Get(), 0)
+
+	tracker.observe(&Partitions{Groups: [][]string{{"a", "b"}}})
+
+	r.InDelta(1, metrics.GetOrCreateGauge(`kubenurse_partition_count`, nil).Get(), 0)
+	r.NotContains(metrics.ListMetricNames(), `kubenurse_node_partition{node="c"}`)
+	r.Contains(metrics.ListMetricNames(), `kubenurse_node_partition{node="b"}`)
+}
@@ -0,0 +1,347 @@
+package kubenurse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/klauspost/compress/snappy"
+	"github.com/postfinance/kubenurse/internal/config"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// pushTimeout is the timeout of a single push request.
+	pushTimeout = 10 * time.Second
+	// pushMinBackoff is the delay before the first retry of a failed push,
+	// it doubles with every further retry.
+	pushMinBackoff = time.Second
+)
+
+// metricsPusher periodically pushes the metrics of /metrics to a Prometheus
+// remote-write or VictoriaMetrics import endpoint.
+type metricsPusher struct {
+	client   *http.Client
+	url      string
+	format   string
+	interval time.Duration
+	backoff  time.Duration
+	// extraLabels are added to the series which don't have them, sorted by
+	// name
+	extraLabels []label
+}
+
+func newMetricsPusher(cfg *config.Config) *metricsPusher {
+	push := &cfg.Metrics.Push
+	p := &metricsPusher{
+		client:   &http.Client{Timeout: pushTimeout},
+		url:      push.URL,
+		format:   push.Format,
+		interval: push.Interval.Duration,
+		backoff:  pushMinBackoff,
+	}
+
+	// the series of the kubenurses are the same, unlike in a scrape the
+	// receiver cannot tell them apart without the node and pod labels
+	extra := maps.Clone(push.ExtraLabels)
+	if extra == nil {
+		extra = make(map[string]string, 2)
+	}
+
+	extra["pod"] = podName(cfg)
+
+	if cfg.Checker.NodeName != "" { // the aggregator doesn't run on a given node
+		extra["node"] = cfg.Checker.NodeName
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(extra)) {
+		p.extraLabels = append(p.extraLabels, label{name: name, value: extra[name]})
+	}
+
+	return p
+}
+
+// run pushes the metrics every interval until ctx is done.
+func (p *metricsPusher) run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.pushWithRetry(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pushWithRetry pushes the current metrics, and retries a failed push with an
+// exponential backoff until the next push is due. The retries send the same
+// samples, with the timestamp of the first attempt.
+func (p *metricsPusher) pushWithRetry(ctx context.Context) {
+	body, err := p.encode(time.Now())
+	if err != nil {
+		slog.Error("cannot encode the metrics to push", "err", err)
+		return
+	}
+
+	deadline := time.Now().Add(p.interval)
+
+	for backoff := p.backoff; ; backoff *= 2 {
+		err := p.push(ctx, body)
+		if err == nil {
+			return
+		}
+
+		if !retryable(err) || time.Now().Add(backoff).After(deadline) {
+			slog.Error("cannot push the metrics", "url", redactURL(p.url), "err", err)
+			return
+		}
+
+		slog.Warn("cannot push the metrics, retrying", "url", redactURL(p.url), "backoff", backoff, "err", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// shutdown pushes the metrics a last time, without retrying.
+func (p *metricsPusher) shutdown(ctx context.Context) error {
+	body, err := p.encode(time.Now())
+	if err != nil {
+		return err
+	}
+
+	return p.push(ctx, body)
+}
+
+// encode returns the compressed request body with the current samples.
+func (p *metricsPusher) encode(now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+
+	metrics.GetDefaultSet().WritePrometheus(&buf)
+
+	samples, err := parseSamples(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.format == config.PushImport {
+		return encodeImport(samples, p.extraLabels, now)
+	}
+
+	return encodeRemoteWrite(samples, p.extraLabels, now), nil
+}
+
+// push sends the body once, and counts the result.
+func (p *metricsPusher) push(ctx context.Context, body []byte) error {
+	err := p.send(ctx, body)
+
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+
+	metrics.GetOrCreateCounter(fmt.Sprintf(`kubenurse_metrics_pushes_total{result=%q}`, result)).Inc()
+
+	return err
+}
+
+func (p *metricsPusher) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	if p.format == config.PushImport {
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Content-Encoding", "gzip")
+	} else {
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	if resp.StatusCode/100 != 2 {
+		return &pushStatusError{code: resp.StatusCode, msg: strings.TrimSpace(string(msg))}
+	}
+
+	return nil
+}
+
+// pushStatusError is the error of a push which was rejected by the endpoint.
+type pushStatusError struct {
+	code int
+	msg  string
+}
+
+func (e *pushStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s: %s", e.code, http.StatusText(e.code), e.msg)
+}
+
+// retryable tells whether a push may succeed if it is retried, which isn't
+// the case if the endpoint rejected it, e.g. because of an invalid label.
+func retryable(err error) bool {
+	var se *pushStatusError
+	if errors.As(err, &se) {
+		return se.code == http.StatusTooManyRequests || se.code >= http.StatusInternalServerError
+	}
+
+	return true
+}
+
+// redactURL hides the password of the url in the logs.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+
+	return u.Redacted()
+}
+
+// seriesLabels returns the labels of a sample and the extra labels it doesn't
+// have, the existing labels take precedence.
+func seriesLabels(s sample, extra []label) []label {
+	labels := slices.Clone(s.labels)
+
+	for _, l := range extra {
+		if !slices.ContainsFunc(s.labels, func(sl label) bool { return sl.name == l.name }) {
+			labels = append(labels, l)
+		}
+	}
+
+	return labels
+}
+
+// encodeRemoteWrite encodes the samples as a snappy compressed remote-write
+// WriteRequest protobuf message, see
+// https://prometheus.io/docs/specs/prw/remote_write_spec/.
+func encodeRemoteWrite(samples []sample, extra []label, now time.Time) []byte {
+	var req, series, buf []byte
+
+	for _, s := range samples {
+		// the labels of a series must be sorted by name, including __name__
+		labels := append(seriesLabels(s, extra), label{name: "__name__", value: s.name})
+		slices.SortFunc(labels, func(a, b label) int { return strings.Compare(a.name, b.name) })
+
+		series = series[:0]
+
+		for _, l := range labels {
+			buf = buf[:0]
+			buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+			buf = protowire.AppendString(buf, l.name)
+			buf = protowire.AppendTag(buf, 2, protowire.BytesType)
+			buf = protowire.AppendString(buf, l.value)
+
+			series = protowire.AppendTag(series, 1, protowire.BytesType)
+			series = protowire.AppendBytes(series, buf)
+		}
+
+		buf = buf[:0]
+		buf = protowire.AppendTag(buf, 1, protowire.Fixed64Type)
+		buf = protowire.AppendFixed64(buf, math.Float64bits(s.value))
+		buf = protowire.AppendTag(buf, 2, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, uint64(now.UnixMilli()))
+
+		series = protowire.AppendTag(series, 2, protowire.BytesType)
+		series = protowire.AppendBytes(series, buf)
+
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, series)
+	}
+
+	return snappy.Encode(nil, req)
+}
+
+// encodeImport encodes the samples as gzip compressed Prometheus text format
+// with timestamps, as accepted by the VictoriaMetrics import API.
+func encodeImport(samples []sample, extra []label, now time.Time) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		ts  = strconv.FormatInt(now.UnixMilli(), 10)
+	)
+
+	zw := gzip.NewWriter(&buf)
+
+	for _, s := range samples {
+		line := []byte(s.name)
+
+		if labels := seriesLabels(s, extra); len(labels) > 0 {
+			line = append(line, '{')
+
+			for i, l := range labels {
+				if i > 0 {
+					line = append(line, ',')
+				}
+
+				line = append(line, l.name...)
+				line = append(line, '=')
+				line = appendLabelValue(line, l.value)
+			}
+
+			line = append(line, '}')
+		}
+
+		line = append(line, ' ')
+		line = strconv.AppendFloat(line, s.value, 'g', -1, 64)
+		line = append(line, ' ')
+		line = append(line, ts...)
+		line = append(line, '\n')
+
+		if _, err := zw.Write(line); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// appendLabelValue appends the quoted label value, in which only the
+// backslash, double quote and line feed are escaped.
+func appendLabelValue(dst []byte, value string) []byte {
+	dst = append(dst, '"')
+
+	for i := range len(value) {
+		switch c := value[i]; c {
+		case '\\', '"':
+			dst = append(dst, '\\', c)
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		default:
+			dst = append(dst, c)
+		}
+	}
+
+	return append(dst, '"')
+}
@@ -0,0 +1,227 @@
+package kubenurse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/klauspost/compress/snappy"
+	"github.com/postfinance/kubenurse/internal/config"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// pushStub is a push endpoint which answers with the given status codes, and
+// then with 204, and keeps the received bodies.
+type pushStub struct {
+	mu       sync.Mutex
+	statuses []int
+	headers  []http.Header
+	bodies   [][]byte
+}
+
+func (p *pushStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.headers = append(p.headers, r.Header)
+	p.bodies = append(p.bodies, body)
+
+	if len(p.statuses) > 0 {
+		w.WriteHeader(p.statuses[0])
+		p.statuses = p.statuses[1:]
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newTestConfig returns the configuration of the pusher of the kubenurse pod
+// running on node.
+func newTestConfig(url, format, node string) *config.Config {
+	cfg := config.Default()
+	cfg.Checker.NodeName = node
+	cfg.Resource.PodName = "kubenurse-" + node
+	cfg.Metrics.Push = config.Push{
+		URL:         url,
+		Format:      format,
+		Interval:    metav1.Duration{Duration: time.Second},
+		ExtraLabels: map[string]string{"cluster": "edge-1", "type": "ignored"},
+	}
+
+	return cfg
+}
+
+func newTestPusher(t *testing.T, format string, statuses ...int) (*metricsPusher, *pushStub) {
+	t.Helper()
+
+	stub := &pushStub{statuses: statuses}
+
+	ts := httptest.NewServer(stub)
+	t.Cleanup(ts.Close)
+
+	p := newMetricsPusher(newTestConfig(ts.URL, format, "a"))
+	p.backoff = 10 * time.Millisecond
+
+	return p, stub
+}
+
+// decodeRemoteWrite decodes the series of a WriteRequest as text lines.
+func decodeRemoteWrite(t *testing.T, body []byte) []string {
+	t.Helper()
+
+	r := require.New(t)
+
+	req, err := snappy.Decode(nil, body)
+	r.NoError(err)
+
+	var lines []string
+
+	for len(req) > 0 {
+		num, typ, n := protowire.ConsumeTag(req)
+		r.Equal(protowire.Number(1), num)
+		r.Equal(protowire.BytesType, typ)
+
+		series, m := protowire.ConsumeBytes(req[n:])
+		r.Positive(m)
+
+		req = req[n+m:]
+
+		var (
+			line string
+			ts   uint64
+		)
+
+		for len(series) > 0 {
+			num, _, n := protowire.ConsumeTag(series)
+			field, m := protowire.ConsumeBytes(series[n:])
+			r.Positive(m)
+
+			series = series[n+m:]
+
+			_, _, n = protowire.ConsumeTag(field)
+			if num == 1 { // label
+				name, m := protowire.ConsumeString(field[n:])
+				_, _, k := protowire.ConsumeTag(field[n+m:])
+				value, _ := protowire.ConsumeString(field[n+m+k:])
+				line += name + "=" + value + " "
+
+				continue
+			}
+
+			value, m := protowire.ConsumeFixed64(field[n:])
+			_, _, k := protowire.ConsumeTag(field[n+m:])
+			ts, _ = protowire.ConsumeVarint(field[n+m+k:])
+			line += strconv.FormatFloat(math.Float64frombits(value), 'g', -1, 64)
+		}
+
+		r.Positive(ts)
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+func TestPushRemoteWrite(t *testing.T) {
+	r := require.New(t)
+
+	metrics.GetOrCreateCounter(`kubenurse_push_test_total{type="me_ingress"}`).Set(3)
+
+	p, stub := newTestPusher(t, config.PushRemoteWrite, http.StatusServiceUnavailable)
+	p.pushWithRetry(context.Background())
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+
+	r.Len(stub.bodies, 2, "the push must be retried after the 503")
+	r.Equal(stub.bodies[0], stub.bodies[1], "the retry must send the same samples")
+	r.Equal("snappy", stub.headers[0].Get("Content-Encoding"))
+	r.Equal("0.1.0", stub.headers[0].Get("X-Prometheus-Remote-Write-Version"))
+
+	// the labels are sorted, and the existing type label wins over the extra one
+	r.Contains(decodeRemoteWrite(t, stub.bodies[1]),
+		"__name__=kubenurse_push_test_total cluster=edge-1 node=a pod=kubenurse-a type=me_ingress 3")
+}
+
+func TestPushSeriesPerPod(t *testing.T) {
+	r := require.New(t)
+
+	metrics.GetOrCreateCounter(`kubenurse_push_test_total{type="me_ingress"}`).Set(3)
+
+	var series []string
+
+	for _, node := range []string{"a", "b"} {
+		body, err := newMetricsPusher(newTestConfig("", config.PushRemoteWrite, node)).encode(time.Now())
+		r.NoError(err)
+
+		for _, line := range decodeRemoteWrite(t, body) {
+			if strings.HasPrefix(line, "__name__=kubenurse_push_test_total ") {
+				series = append(series, line)
+			}
+		}
+	}
+
+	r.Equal([]string{
+		"__name__=kubenurse_push_test_total cluster=edge-1 node=a pod=kubenurse-a type=me_ingress 3",
+		"__name__=kubenurse_push_test_total cluster=edge-1 node=b pod=kubenurse-b type=me_ingress 3",
+	}, series)
+
+	// the aggregator has no node
+	cfg := newTestConfig("", config.PushImport, "")
+	r.Equal([]label{{"cluster", "edge-1"}, {"pod", "kubenurse-"}, {"type", "ignored"}}, newMetricsPusher(cfg).extraLabels)
+}
+
+func TestPushImport(t *testing.T) {
+	r := require.New(t)
+
+	metrics.GetOrCreateCounter(`kubenurse_push_test_total{type="me_ingress"}`).Set(3)
+	metrics.GetOrCreateGauge(`kubenurse_push_test_info{msg="a \"b\"\\"}`, nil).Set(1)
+
+	p, stub := newTestPusher(t, config.PushImport, http.StatusBadRequest)
+	p.pushWithRetry(context.Background())
+
+	r.Len(stub.bodies, 1, "a rejected push must not be retried")
+
+	r.NoError(p.shutdown(context.Background()))
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+
+	r.Len(stub.bodies, 2)
+	r.Equal("gzip", stub.headers[1].Get("Content-Encoding"))
+
+	zr, err := gzip.NewReader(bytes.NewReader(stub.bodies[1]))
+	r.NoError(err)
+
+	text, err := io.ReadAll(zr)
+	r.NoError(err)
+
+	samples, err := parseSamples(bytes.NewReader(text))
+	r.NoError(err)
+	r.Contains(samples, sample{
+		name:   "kubenurse_push_test_total",
+		labels: []label{{"type", "me_ingress"}, {"cluster", "edge-1"}, {"node", "a"}, {"pod", "kubenurse-a"}},
+		value:  3,
+	})
+	r.Contains(samples, sample{
+		name:   "kubenurse_push_test_info",
+		labels: []label{{"msg", `a "b"\`}, {"cluster", "edge-1"}, {"node", "a"}, {"pod", "kubenurse-a"}, {"type", "ignored"}},
+		value:  1,
+	})
+	r.Contains(string(text), `kubenurse_push_test_info{msg="a \"b\"\\",cluster="edge-1",node="a",pod="kubenurse-a",type="ignored"} 1 `)
+}
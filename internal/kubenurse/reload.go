@@ -0,0 +1,231 @@
+package kubenurse
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"log/slog"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/postfinance/kubenurse/internal/config"
+	"github.com/postfinance/kubenurse/internal/servicecheck"
+)
+
+// configureChecker applies the checker and checks sections of cfg to chk.
+func configureChecker(chk *servicecheck.Checker, cfg *config.Config) {
+	chk.ShutdownDuration = cfg.Server.ShutdownDuration.Duration
+	chk.KubenurseIngressURL = cfg.Checker.IngressURL
+	chk.KubenurseServiceURL = cfg.Checker.ServiceURL
+	chk.KubernetesServiceHost = cfg.Checker.KubernetesServiceHost
+	chk.KubernetesServicePort = cfg.Checker.KubernetesServicePort
+	chk.KubernetesServiceDNS = cfg.Checker.KubernetesServiceDNS
+	chk.KubenurseNamespace = cfg.Checker.Namespace
+	chk.NodeName = cfg.Checker.NodeName
+
+	if chk.NodeName == "" && cfg.Discovery.Standalone() {
+		// the peer whose host is the hostname is the kubenurse itself
+		chk.NodeName, _ = os.Hostname()
+	}
+
+	chk.NeighbourFilter = cfg.Checker.NeighbourFilter
+	chk.NeighbourLimit = cfg.Checker.NeighbourLimit
+	chk.NeighbourSelection = cfg.Checker.NeighbourSelection
+	chk.NeighbourTopology = servicecheck.NeighbourTopology{
+		SameZone:  cfg.Checker.NeighbourTopology.SameZone,
+		PerZone:   cfg.Checker.NeighbourTopology.PerZone,
+		PerRegion: cfg.Checker.NeighbourTopology.PerRegion,
+	}
+	chk.NeighbourRotation = cfg.Checker.NeighbourRotation.Duration
+	chk.HistoryDepth = cfg.Checker.HistoryDepth
+
+	// there is no API server to check in the standalone mode
+	chk.SkipCheckAPIServerDirect = !cfg.Checks.APIServerDirect || cfg.Discovery.Standalone()
+	chk.SkipCheckAPIServerDNS = !cfg.Checks.APIServerDNS || cfg.Discovery.Standalone()
+	chk.SkipCheckMeIngress = !cfg.Checks.MeIngress
+	chk.SkipCheckMeService = !cfg.Checks.MeService
+	chk.SkipCheckNeighbourhood = !cfg.Checks.Neighbourhood
+	chk.NeighbourBurstProbes = cfg.Checks.NeighbourhoodBurst.Probes
+	chk.NeighbourBurstGap = cfg.Checks.NeighbourhoodBurst.Gap.Duration
+
+	chk.ClusterChecks = cfg.Checks.ClusterChecks
+
+	chk.CheckDNS = cfg.Checks.DNS.Enabled
+	chk.DNSServiceNamespace = cfg.Checks.DNS.Namespace
+	chk.DNSServiceName = cfg.Checks.DNS.Service
+	chk.DNSTimeout = cfg.Checks.DNS.Timeout.Duration
+	chk.DNSQueries = []servicecheck.DNSQuery{{Name: cfg.Checker.KubernetesServiceDNS, Type: "A"}}
+
+	if len(cfg.Checks.DNS.Queries) > 0 {
+		chk.DNSQueries = make([]servicecheck.DNSQuery, 0, len(cfg.Checks.DNS.Queries))
+		for _, q := range cfg.Checks.DNS.Queries {
+			chk.DNSQueries = append(chk.DNSQueries, servicecheck.DNSQuery{Name: q.Name, Type: q.Type})
+		}
+	}
+
+	chk.CheckKubelet = cfg.Checks.NodeHealth.Kubelet
+	chk.KubeletPort = cfg.Checks.NodeHealth.KubeletPort
+	chk.CheckKubeProxy = cfg.Checks.NodeHealth.KubeProxy
+	chk.KubeProxyPort = cfg.Checks.NodeHealth.KubeProxyPort
+	chk.CheckNeighbourNodeHealth = cfg.Checks.NodeHealth.Neighbours
+
+	chk.CheckHostPath = cfg.Checks.HostPath.Enabled
+	chk.HostPathPort = cfg.Checks.HostPath.Port
+
+	chk.MTUSizes = cfg.Checks.MTU.Sizes
+	chk.MTUTimeout = cfg.Checks.MTU.Timeout.Duration
+
+	chk.CheckUDP = cfg.Checks.UDP.Enabled
+	chk.UDPPort = cfg.Checks.UDP.Port
+	chk.UDPProbes = cfg.Checks.UDP.Probes
+	chk.UDPTimeout = cfg.Checks.UDP.Timeout.Duration
+
+	extraChecks := make(map[string]servicecheck.ExtraCheck, len(cfg.Checks.Extra))
+	for _, ec := range cfg.Checks.Extra {
+		extraChecks[ec.Name] = servicecheck.ExtraCheck{
+			URL:            ec.URL,
+			Method:         ec.Method,
+			ExpectedStatus: ec.ExpectedStatus,
+			Interval:       ec.Interval.Duration,
+			Timeout:        ec.Timeout.Duration,
+		}
+	}
+
+	chk.ExtraChecks = extraChecks
+}
+
+// reload applies a new configuration. It must only be called when no check
+// is running. Settings which are only read at startup are kept, and a warning
+// is logged if they changed. cfg is published as the current configuration,
+// and must not be modified afterwards.
+func (s *Server) reload(cfg *config.Config) {
+	cur := s.currentConfig()
+
+	if cfg.Server != cur.Server ||
+		cfg.Checker.AllowUnschedulable != cur.Checker.AllowUnschedulable ||
+		cfg.Checker.Namespace != cur.Checker.Namespace ||
+		cfg.Checks.DNS.Namespace != cur.Checks.DNS.Namespace ||
+		cfg.Checks.UDP.Enabled != cur.Checks.UDP.Enabled ||
+		cfg.Checks.UDP.Port != cur.Checks.UDP.Port ||
+		cfg.Checks.HostPath != cur.Checks.HostPath ||
+		!reflect.DeepEqual(cfg.Discovery, cur.Discovery) ||
+		cfg.Analysis != cur.Analysis ||
+		cfg.Events != cur.Events ||
+		!reflect.DeepEqual(cfg.Metrics, cur.Metrics) ||
+		cfg.Tracing != cur.Tracing ||
+		cfg.Resource != cur.Resource {
+		slog.Warn("configuration changes in the server, discovery, analysis, events, metrics, tracing and resource sections, checker.allowUnschedulable, " +
+			"checker.namespace, checks.dns.namespace, checks.udp.enabled, checks.udp.port and checks.hostPath " +
+			"require a restart")
+
+		cfg.Server = cur.Server
+		cfg.Checker.AllowUnschedulable = cur.Checker.AllowUnschedulable
+		cfg.Checker.Namespace = cur.Checker.Namespace
+		cfg.Checks.DNS.Namespace = cur.Checks.DNS.Namespace
+		cfg.Checks.UDP.Enabled = cur.Checks.UDP.Enabled
+		cfg.Checks.UDP.Port = cur.Checks.UDP.Port
+		cfg.Checks.HostPath = cur.Checks.HostPath
+		cfg.Discovery = cur.Discovery
+		cfg.Analysis = cur.Analysis
+		cfg.Events = cur.Events
+		cfg.Metrics = cur.Metrics
+		cfg.Tracing = cur.Tracing
+		cfg.Resource = cur.Resource
+	}
+
+	configureChecker(s.checker, cfg)
+	s.checkInterval = cfg.Checker.Interval.Duration
+	s.cfg.Store(cfg)
+
+	slog.Info("configuration reloaded", "file", cfg.File)
+}
+
+// watchConfig polls the configuration file and sends every valid new
+// configuration on the returned channel. The channel is nil, and thus never
+// ready, if kubenurse wasn't started with a configuration file.
+func (s *Server) watchConfig(ctx context.Context) <-chan *config.Config {
+	cfg := s.currentConfig()
+	if cfg.File == "" {
+		return nil
+	}
+
+	var (
+		path     = cfg.File
+		interval = cfg.Server.ReloadInterval.Duration
+		reloadc  = make(chan *config.Config)
+	)
+
+	// the checksum of the file content is compared rather than its
+	// modification time, as ConfigMap volumes are updated by swapping symlinks
+	var (
+		lastSum = fileChecksum(path)
+		// pendingSum is the checksum of a changed file, which is only loaded
+		// once it stays the same for a poll, so a partially written file isn't
+		pendingSum []byte
+	)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+
+			sum := fileChecksum(path)
+			if sum == nil || bytes.Equal(sum, lastSum) {
+				pendingSum = nil
+				continue
+			}
+
+			if !bytes.Equal(sum, pendingSum) {
+				pendingSum = sum
+				continue
+			}
+
+			lastSum, pendingSum = sum, nil
+
+			cfg, err := config.Load(path)
+			if err != nil {
+				metrics.GetOrCreateCounter(`kubenurse_config_reloads_total{result="error"}`).Inc()
+				slog.Error("invalid configuration, keeping the current one", "file", path, "err", err)
+
+				continue
+			}
+
+			metrics.GetOrCreateCounter(`kubenurse_config_reloads_total{result="success"}`).Inc()
+
+			select {
+			case reloadc <- cfg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return reloadc
+}
+
+// fileChecksum returns the checksum of the file content, or nil if the file
+// cannot be read or is empty, as a truncated file would be loaded as the
+// default configuration.
+func fileChecksum(path string) []byte {
+	b, err := os.ReadFile(path) //nolint:gosec // Intentionally included by the user.
+	if err != nil {
+		slog.Error("cannot read configuration file", "file", path, "err", err)
+		return nil
+	}
+
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+
+	sum := sha256.Sum256(b)
+
+	return sum[:]
+}
@@ -0,0 +1,145 @@
+package kubenurse
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/postfinance/kubenurse/internal/config"
+	"github.com/postfinance/kubenurse/internal/servicecheck"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// writeFile replaces the file atomically, like the kubelet updates the
+// ConfigMap volumes.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	tmp := filepath.Join(filepath.Dir(path), ".tmp")
+	require.NoError(t, os.WriteFile(tmp, []byte(content), 0o600))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+func TestReload(t *testing.T) {
+	r := require.New(t)
+
+	// the environment overrides the configuration file
+	t.Setenv("KUBENURSE_EXTRA_CHECKS", "")
+
+	path := filepath.Join(t.TempDir(), "kubenurse.yaml")
+	writeFile(t, path, `
+server:
+  reloadInterval: 10ms
+checks:
+  extra:
+  - name: first
+    url: http://first.example.com
+`)
+
+	cfg, err := config.Load(path)
+	r.NoError(err)
+
+	kubenurse, err := New(fake.NewFakeClient(), cfg)
+	r.NoError(err)
+	r.Equal(map[string]servicecheck.ExtraCheck{"first": {URL: "http://first.example.com"}}, kubenurse.checker.ExtraChecks)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reloadc := kubenurse.watchConfig(ctx)
+	r.NotNil(reloadc)
+
+	// an invalid configuration is ignored
+	writeFile(t, path, "checker:\n  interval: -1s\n")
+	time.Sleep(50 * time.Millisecond)
+
+	// as is an empty file, which would be loaded as the defaults
+	writeFile(t, path, "\n")
+	time.Sleep(50 * time.Millisecond)
+
+	writeFile(t, path, `
+server:
+  reloadInterval: 10ms
+  useTLS: true
+  certFile: /tls.crt
+  certKey: /tls.key
+checker:
+  interval: 1m
+checks:
+  meIngress: false
+  extra:
+  - name: second
+    url: http://second.example.com
+`)
+
+	select {
+	case newCfg := <-reloadc:
+		kubenurse.reload(newCfg)
+	case <-ctx.Done():
+		r.FailNow("configuration was not reloaded")
+	}
+
+	r.Equal(map[string]servicecheck.ExtraCheck{"second": {URL: "http://second.example.com"}}, kubenurse.checker.ExtraChecks)
+	r.True(kubenurse.checker.SkipCheckMeIngress)
+	r.Equal(time.Minute, kubenurse.checkInterval)
+	r.False(kubenurse.currentConfig().Server.UseTLS, "server settings require a restart")
+}
+
+// TestReloadWhileServing reloads the configuration while the mesh endpoints
+// are served, run it with -race.
+func TestReloadWhileServing(t *testing.T) {
+	r := require.New(t)
+
+	t.Setenv("KUBENURSE_EXTRA_CHECKS", "")
+
+	cfg, err := config.Load("")
+	r.NoError(err)
+
+	kubenurse, err := New(fake.NewFakeClient(), cfg)
+	r.NoError(err)
+
+	kubenurse.meshCacheTTL = 0 // every /mesh lists the kubenurse pods
+
+	ts := httptest.NewServer(kubenurse.http.Handler)
+	defer ts.Close()
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for i := range 50 {
+			newCfg, err := config.Load("")
+			if err != nil {
+				continue
+			}
+
+			newCfg.Checker.NodeName = "node-" + strconv.Itoa(i)
+			newCfg.Checker.NeighbourFilter = "app=kubenurse-" + strconv.Itoa(i)
+			kubenurse.reload(newCfg)
+		}
+	}()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+
+		for _, path := range []string{"/mesh/local", "/mesh"} {
+			resp, err := http.Get(ts.URL + path)
+			r.NoError(err)
+			r.NoError(resp.Body.Close())
+		}
+	}
+
+	r.Equal("node-49", kubenurse.checker.NodeName)
+	r.Equal("app=kubenurse-49", kubenurse.currentConfig().Checker.NeighbourFilter)
+}
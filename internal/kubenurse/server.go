@@ -5,72 +5,91 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/metrics"
+	"github.com/postfinance/kubenurse/internal/config"
 	"github.com/postfinance/kubenurse/internal/servicecheck"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-const defaultCheckInterval = 5 * time.Second
-
 // Server is used to build the kubenurse http/https server(s).
 type Server struct {
 	http  http.Server
 	https http.Server
 
-	checker *servicecheck.Checker
+	// host is the listener of the host path check, reachable on the node IP
+	host http.Server
+
+	// udpEcho is the listener of the UDP check, nil if the check is disabled
+	udpEcho       net.PacketConn
+	udpEchoClosed bool
+	udpEchoMu     sync.Mutex
 
-	// Configuration options
+	checker *servicecheck.Checker
+	client  client.Client
+
+	// meshClient queries the /mesh/local endpoint of the other kubenurses,
+	// on the meshPort of their http listener
+	meshClient *http.Client
+	meshPort   string
+
+	// meshCache is the last collected mesh, served by /mesh until it is
+	// older than meshCacheTTL, the analysis interval
+	meshCache    *Mesh
+	meshCacheAt  time.Time
+	meshCacheTTL time.Duration
+	meshCacheMu  sync.Mutex
+
+	// cfg is the current configuration, it is replaced as a whole on a
+	// reload and never modified once stored
+	cfg           atomic.Pointer[config.Config]
 	useTLS        bool
+	certFile      string
+	certKey       string
 	checkInterval time.Duration
-	// If we want to consider kubenurses on unschedulable nodes
-	allowUnschedulable bool
 
 	ready     atomic.Bool
 	nodeReady atomic.Bool
 
 	neighboursTTLCache TTLCache[string]
+
+	// reporter reports the check transitions, nil if it is disabled
+	reporter *checkReporter
+
+	// nodeName is the node of the kubenurse, nil until it was discovered by
+	// the node readiness watcher
+	nodeName atomic.Pointer[string]
+	// condition publishes the condition of the node, nil until it is enabled
+	condition *nodeConditionPublisher
+
+	// tracerProvider exports the traces of the checks, nil if the tracing is
+	// disabled
+	tracerProvider *sdktrace.TracerProvider
+	// meterProvider pushes the metrics with OTLP, nil if it is disabled
+	meterProvider *sdkmetric.MeterProvider
+	// pusher pushes the metrics with Prometheus remote-write or the
+	// VictoriaMetrics import API, nil if it is disabled
+	pusher *metricsPusher
 }
 
-// New creates a new kubenurse server. The server can be configured with the following environment variables:
-// * KUBENURSE_USE_TLS
-// * KUBENURSE_ALLOW_UNSCHEDULABLE
-// * KUBENURSE_INGRESS_URL
-// * KUBENURSE_SERVICE_URL
-// * KUBERNETES_SERVICE_HOST
-// * KUBERNETES_SERVICE_PORT
-// * KUBENURSE_NAMESPACE
-// * KUBENURSE_NEIGHBOUR_FILTER
-// * KUBENURSE_NEIGHBOUR_LIMIT
-// * KUBENURSE_SHUTDOWN_DURATION
-// * KUBENURSE_CHECK_API_SERVER_DIRECT
-// * KUBENURSE_CHECK_API_SERVER_DNS
-// * KUBENURSE_CHECK_ME_INGRESS
-// * KUBENURSE_CHECK_ME_SERVICE
-// * KUBENURSE_CHECK_NEIGHBOURHOOD
-// * KUBENURSE_CHECK_INTERVAL
-// * KUBENURSE_EXPOSE_METADATA
-func New(c client.Client) (*Server, error) { //nolint:funlen // TODO: use a flag parsing library (e.g. ff) to reduce complexity
+// New creates a new kubenurse server from the given configuration, see the
+// config package for the available settings and their environment variables.
+func New(c client.Client, cfg *config.Config) (*Server, error) {
 	mux := http.NewServeMux()
-
-	checkInterval := defaultCheckInterval
-
-	if v, ok := os.LookupEnv("KUBENURSE_CHECK_INTERVAL"); ok {
-		var err error
-		checkInterval, err = time.ParseDuration(v)
-		if err != nil {
-			return nil, err
-		}
-	}
+	// the host listener is exposed on the node IP, outside of the network
+	// policies of the pod network, and only serves the endpoint of the checks
+	hostMux := http.NewServeMux()
 
 	server := &Server{
 		http: http.Server{
@@ -87,126 +106,126 @@ func New(c client.Client) (*Server, error) { //nolint:funlen // TODO: use a flag
 			WriteTimeout:      10 * time.Second,
 			IdleTimeout:       120 * time.Second,
 		},
+		host: http.Server{
+			Addr:              ":" + strconv.Itoa(cfg.Checks.HostPath.Port),
+			Handler:           hostMux,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      10 * time.Second,
+			IdleTimeout:       120 * time.Second,
+		},
 
-		//nolint:goconst // No need to make "true" a constant in my opinion, readability is better like this.
-		useTLS:             os.Getenv("KUBENURSE_USE_TLS") == "true",
-		allowUnschedulable: os.Getenv("KUBENURSE_ALLOW_UNSCHEDULABLE") == "true",
-		checkInterval:      checkInterval,
-		ready:              atomic.Bool{},
+		client:        c,
+		meshClient:    &http.Client{Timeout: meshRequestTimeout},
+		meshPort:      "8080",
+		meshCacheTTL:  cfg.Analysis.Interval.Duration,
+		useTLS:        cfg.Server.UseTLS,
+		certFile:      cfg.Server.CertFile,
+		certKey:       cfg.Server.CertKey,
+		checkInterval: cfg.Checker.Interval.Duration,
+		ready:         atomic.Bool{},
 	}
 
+	server.cfg.Store(cfg)
 	server.ready.Store(true)
 	server.nodeReady.Store(true)
 	server.neighboursTTLCache.Init(60 * time.Second)
 
-	if os.Getenv("KUBENURSE_EXPOSE_METADATA") == "true" {
+	if cfg.Metrics.ExposeMetadata {
 		metrics.ExposeMetadata(true)
 	}
 
-	var histogramBuckets []float64
-
-	if bucketsString := os.Getenv("KUBENURSE_HISTOGRAM_BUCKETS"); bucketsString != "" {
-		for bucketStr := range strings.SplitSeq(bucketsString, ",") {
-			bucket, err := strconv.ParseFloat(bucketStr, 64)
-			if err != nil {
-				slog.Error("couldn't parse one of the custom histogram buckets", "bucket", bucket, "err", err)
-				os.Exit(1)
-			}
-
-			histogramBuckets = append(histogramBuckets, bucket)
-		}
-
-		err := metrics.ValidateBuckets(histogramBuckets)
-		if err != nil {
-			slog.Error("custom histogram buckets validation failed", "bucket_bounds", histogramBuckets, "err", err)
-			os.Exit(1)
-		}
-	}
-
-	// setup checker
-	chk, err := servicecheck.New(c, server.allowUnschedulable, 1*time.Second, func(s string) servicecheck.Histogram {
-		if os.Getenv("KUBENURSE_VICTORIAMETRICS_HISTOGRAM") == "true" {
-			return metrics.GetOrCreateHistogram(s)
-		} else {
-			if histogramBuckets == nil {
-				histogramBuckets = metrics.PrometheusHistogramDefaultBuckets
-			}
-			return metrics.GetOrCreatePrometheusHistogramExt(s, histogramBuckets)
-		}
-	})
+	chk, err := NewChecker(c, cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	shutdownDuration := 5 * time.Second
+	server.checker = chk
 
-	if v, ok := os.LookupEnv("KUBENURSE_SHUTDOWN_DURATION"); ok {
-		shutdownDuration, err = time.ParseDuration(v)
-		if err != nil {
+	if cfg.Tracing.Endpoint != "" {
+		if server.tracerProvider, err = newTracerProvider(cfg); err != nil {
 			return nil, err
 		}
+
+		chk.TracerProvider = server.tracerProvider
 	}
 
-	chk.ShutdownDuration = shutdownDuration
-	chk.KubenurseIngressURL = os.Getenv("KUBENURSE_INGRESS_URL")
-	chk.KubenurseServiceURL = os.Getenv("KUBENURSE_SERVICE_URL")
-	chk.KubernetesServiceHost = os.Getenv("KUBERNETES_SERVICE_HOST")
-	chk.KubernetesServicePort = os.Getenv("KUBERNETES_SERVICE_PORT")
-	chk.KubernetesServiceDNS = getOrDefault("KUBERNETES_SERVICE_DNS", "kubernetes.default.svc.cluster.local")
-	chk.KubenurseNamespace = os.Getenv("KUBENURSE_NAMESPACE")
-	chk.NeighbourFilter = os.Getenv("KUBENURSE_NEIGHBOUR_FILTER")
-	neighLimit := os.Getenv("KUBENURSE_NEIGHBOUR_LIMIT")
-
-	if neighLimit != "" {
-		chk.NeighbourLimit, err = strconv.Atoi(neighLimit)
-		if err != nil {
+	if cfg.Metrics.OTLP.Endpoint != "" {
+		if server.meterProvider, err = newMeterProvider(cfg); err != nil {
 			return nil, err
 		}
-	} else {
-		chk.NeighbourLimit = 10
 	}
 
-	//nolint:goconst // No need to make "false" a constant in my opinion, readability is better like this.
-	chk.SkipCheckAPIServerDirect = os.Getenv("KUBENURSE_CHECK_API_SERVER_DIRECT") == "false"
-	chk.SkipCheckAPIServerDNS = os.Getenv("KUBENURSE_CHECK_API_SERVER_DNS") == "false"
-	chk.SkipCheckMeIngress = os.Getenv("KUBENURSE_CHECK_ME_INGRESS") == "false"
-	chk.SkipCheckMeService = os.Getenv("KUBENURSE_CHECK_ME_SERVICE") == "false"
-	chk.SkipCheckNeighbourhood = os.Getenv("KUBENURSE_CHECK_NEIGHBOURHOOD") == "false"
-
-	chk.UseTLS = server.useTLS
-
-	// Extra checks parsing
-	if extraChecks := os.Getenv("KUBENURSE_EXTRA_CHECKS"); extraChecks != "" {
-		for _, extraCheck := range strings.Split(extraChecks, "|") {
-			requestType, url, fnd := strings.Cut(extraCheck, ":")
-			if !fnd {
-				slog.Error("couldn't parse one of extraChecks", "extraCheck", extraCheck)
-				return nil, fmt.Errorf("extra checks parsing - missing colon ':' between metric name and url")
-			}
-
-			chk.ExtraChecks[requestType] = url
-		}
+	if cfg.Metrics.Push.URL != "" {
+		server.pusher = newMetricsPusher(cfg)
 	}
 
-	server.checker = chk
-
 	// setup http routes
 	mux.HandleFunc("/ready", server.readyHandler())
 	mux.HandleFunc("/alive", server.aliveHandler())
 	mux.HandleFunc("/alwayshappy", server.alwaysHappyHandler())
+	mux.HandleFunc("/history", server.historyHandler())
+	mux.HandleFunc("/mesh", server.meshHandler())
+	mux.HandleFunc("/mesh/local", server.meshLocalHandler())
 	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
 		metrics.WritePrometheus(w, true)
 	})
 	mux.Handle("/", http.RedirectHandler("/alive", http.StatusMovedPermanently))
 
+	hostMux.HandleFunc("/alwayshappy", server.alwaysHappyHandler())
+
 	return server, nil
 }
 
+// NewChecker creates the checker of the kubenurse server, configured with
+// the checks and histograms of cfg.
+func NewChecker(c client.Client, cfg *config.Config) (*servicecheck.Checker, error) {
+	histogramBuckets := cfg.Metrics.HistogramBuckets
+	if len(histogramBuckets) == 0 {
+		histogramBuckets = metrics.PrometheusHistogramDefaultBuckets
+	}
+
+	chk, err := servicecheck.New(c, cfg.Checker.AllowUnschedulable, 1*time.Second, func(s string) servicecheck.Histogram {
+		if cfg.Metrics.VictoriaMetricsHistogram {
+			return metrics.GetOrCreateHistogram(s)
+		}
+
+		return metrics.GetOrCreatePrometheusHistogramExt(s, histogramBuckets)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chk.UseTLS = cfg.Server.UseTLS
+	chk.Discovery = neighbourDiscovery(chk, &cfg.Discovery)
+	configureChecker(chk, cfg)
+
+	return chk, nil
+}
+
+// neighbourDiscovery returns the discovery of the configured mode, or nil to
+// list the kubenurse pods.
+func neighbourDiscovery(chk *servicecheck.Checker, d *config.Discovery) servicecheck.NeighbourDiscovery {
+	switch d.Mode {
+	case config.DiscoveryEndpointSlices:
+		return chk.EndpointSliceDiscovery(d.Service)
+	case config.DiscoveryStatic:
+		return servicecheck.StaticPeers(d.Peers)
+	case config.DiscoveryFile:
+		return servicecheck.PeersFile(d.PeersFile)
+	case config.DiscoveryDNS:
+		return &servicecheck.DNSPeers{Name: d.PeersDNS}
+	default:
+		return nil
+	}
+}
+
 // Run starts the periodic checker and the http/https server(s) and blocks until Shutdown was called.
 func (s *Server) Run(ctx context.Context) error {
 	var (
 		wg   sync.WaitGroup
-		errc = make(chan error, 2) // max two errors can happen
+		errc = make(chan error, 4) // max four errors can happen
+		// the listeners and the analysis are only configured at startup
+		cfg = s.currentConfig()
 	)
 
 	go func() { // update the incoming neighbouring check gauge every second
@@ -228,10 +247,23 @@ func (s *Server) Run(ctx context.Context) error {
 		ticker := time.NewTicker(s.checkInterval)
 		defer ticker.Stop()
 
+		reloadc := s.watchConfig(ctx)
+
 		for {
 			select {
 			case <-ticker.C:
 				s.checker.Run(ctx)
+
+				if s.reporter != nil {
+					s.reporter.report(ctx, s.checker.LastResults().Checks)
+				}
+
+				s.publishNodeCondition(ctx)
+			case cfg := <-reloadc:
+				// the checker is reconfigured between two runs, so a run
+				// never sees a partially applied configuration
+				s.reload(cfg)
+				ticker.Reset(s.checkInterval)
 			case <-ctx.Done():
 				return
 			}
@@ -250,16 +282,59 @@ func (s *Server) Run(ctx context.Context) error {
 		}
 	}()
 
+	if cfg.Checks.HostPath.Enabled {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := s.host.ListenAndServe(); err != nil {
+				if err != http.ErrServerClosed {
+					errc <- fmt.Errorf("listen host: %w", err)
+				}
+			}
+		}()
+	}
+
+	if cfg.Analysis.Blame || cfg.Analysis.Partitions {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			s.runAnalysis(ctx, cfg.Analysis)
+		}()
+	}
+
+	if s.pusher != nil {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			s.pusher.run(ctx)
+		}()
+	}
+
+	if cfg.Checks.UDP.Enabled {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := s.serveUDPEcho(cfg.Checks.UDP.Port); err != nil {
+				errc <- fmt.Errorf("listen udp: %w", err)
+			}
+		}()
+	}
+
 	if s.useTLS {
 		wg.Add(1)
 
 		go func() {
 			defer wg.Done()
 
-			if err := s.https.ListenAndServeTLS(
-				os.Getenv("KUBENURSE_CERT_FILE"),
-				os.Getenv("KUBENURSE_CERT_KEY"),
-			); err != nil {
+			if err := s.https.ListenAndServeTLS(s.certFile, s.certKey); err != nil {
 				if err != http.ErrServerClosed {
 					errc <- fmt.Errorf("listen https: %w", err)
 				}
@@ -282,15 +357,60 @@ func (s *Server) Run(ctx context.Context) error {
 	return nil
 }
 
+// currentConfig returns the current configuration, which must not be modified.
+// It is safe to call concurrently with a reload.
+func (s *Server) currentConfig() *config.Config {
+	return s.cfg.Load()
+}
+
+// serveUDPEcho listens on port for the datagrams of the neighbours UDP checks
+// and echoes them, until Shutdown is called.
+func (s *Server) serveUDPEcho(port int) error {
+	conn, err := net.ListenPacket("udp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return err
+	}
+
+	s.udpEchoMu.Lock()
+	if s.udpEchoClosed { // Shutdown was called before the listener was ready
+		s.udpEchoMu.Unlock()
+		return conn.Close()
+	}
+
+	s.udpEcho = conn
+	s.udpEchoMu.Unlock()
+
+	return servicecheck.ServeUDPEcho(conn)
+}
+
+// publishNodeCondition publishes the condition of the node from the results of
+// the last run, if it is enabled and the node is known.
+func (s *Server) publishNodeCondition(ctx context.Context) {
+	nodeName := s.nodeName.Load()
+	cfg := s.currentConfig().NodeCondition
+
+	if !cfg.Enabled || nodeName == nil {
+		return
+	}
+
+	if s.condition == nil {
+		s.condition = &nodeConditionPublisher{client: s.client}
+	}
+
+	s.condition.observe(ctx, *nodeName, s.checker.LastResults().Checks, &cfg)
+}
+
 // Shutdown disables the readiness probe and then gracefully halts the kubenurse http/https server(s).
 func (s *Server) Shutdown() error {
+	cfg := s.currentConfig()
+
 	s.ready.Store(false)
 
 	// wait before actually shutting down the http/s server, as the updated
 	// endpoints for the kubenurse service might not have propagated everywhere
 	// (other kubenurse/ingress controller) yet, which will lead to
 	// me_ingress or path errors in other pods
-	time.Sleep(s.checker.ShutdownDuration)
+	time.Sleep(cfg.Server.ShutdownDuration.Duration)
 
 	// background ctx since, the "root" context is already canceled
 	ctx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -306,6 +426,41 @@ func (s *Server) Shutdown() error {
 		}
 	}
 
+	if cfg.Checks.HostPath.Enabled {
+		if err := s.host.Shutdown(ctx); err != nil {
+			return fmt.Errorf("stop host server: %w", err)
+		}
+	}
+
+	if s.tracerProvider != nil {
+		if err := s.tracerProvider.Shutdown(ctx); err != nil {
+			return fmt.Errorf("stop tracer provider: %w", err)
+		}
+	}
+
+	if s.meterProvider != nil { // pushes the metrics a last time
+		if err := s.meterProvider.Shutdown(ctx); err != nil {
+			return fmt.Errorf("stop meter provider: %w", err)
+		}
+	}
+
+	if s.pusher != nil { // pushes the metrics a last time
+		if err := s.pusher.shutdown(ctx); err != nil {
+			return fmt.Errorf("push metrics: %w", err)
+		}
+	}
+
+	s.udpEchoMu.Lock()
+	defer s.udpEchoMu.Unlock()
+
+	s.udpEchoClosed = true
+
+	if s.udpEcho != nil {
+		if err := s.udpEcho.Close(); err != nil {
+			return fmt.Errorf("stop udp echo listener: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -316,27 +471,9 @@ func (s *Server) Shutdown() error {
 func (s *Server) StartNodeReadinessWatcher(ctx context.Context, c client.Client) {
 	go func() {
 		var nodeName string
-		hostname, _ := os.Hostname()
 
-		// Find the current pod to determine the node name. Use the same label
-		// selector and namespace as the neighbouring discovery logic.
 		for nodeName == "" {
-			pods := v1.PodList{}
-			selector, _ := labels.Parse(s.checker.NeighbourFilter)
-
-			if err := c.List(ctx, &pods, &client.ListOptions{
-				LabelSelector: selector,
-				Namespace:     s.checker.KubenurseNamespace,
-			}); err == nil {
-				for i := range pods.Items {
-					if pods.Items[i].Name == hostname {
-						nodeName = pods.Items[i].Spec.NodeName
-						break
-					}
-				}
-			}
-
-			if nodeName == "" {
+			if nodeName = s.localNodeName(ctx, c); nodeName == "" {
 				select {
 				case <-ctx.Done():
 					return
@@ -346,6 +483,7 @@ func (s *Server) StartNodeReadinessWatcher(ctx context.Context, c client.Client)
 		}
 
 		slog.Info("watching local node for readiness", "node", nodeName)
+		s.nodeName.Store(&nodeName)
 
 		wasReady := true
 
@@ -386,10 +524,36 @@ func (s *Server) StartNodeReadinessWatcher(ctx context.Context, c client.Client)
 	}()
 }
 
-func getOrDefault(envVar, defaultVal string) string {
-	if val := os.Getenv(envVar); val != "" {
-		return val
+// localNodeName returns the node of the kubenurse: the configured node name,
+// else the node found by the last run. As a fallback, it is looked up in the
+// kubenurse pods, with the same label selector and namespace as the
+// neighbouring discovery logic. It is empty if the node is unknown.
+func (s *Server) localNodeName(ctx context.Context, c client.Client) string {
+	cfg := s.currentConfig()
+	if cfg.Checker.NodeName != "" {
+		return cfg.Checker.NodeName
+	}
+
+	if last := s.checker.LastResults(); last != nil && last.Node != "" {
+		return last.Node
+	}
+
+	hostname, _ := os.Hostname()
+	pods := v1.PodList{}
+	selector, _ := labels.Parse(cfg.Checker.NeighbourFilter)
+
+	if err := c.List(ctx, &pods, &client.ListOptions{
+		LabelSelector: selector,
+		Namespace:     cfg.Checker.Namespace,
+	}); err != nil {
+		return ""
+	}
+
+	for i := range pods.Items {
+		if pods.Items[i].Name == hostname {
+			return pods.Items[i].Spec.NodeName
+		}
 	}
 
-	return defaultVal
+	return ""
 }
@@ -6,7 +6,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/postfinance/kubenurse/internal/config"
+	"github.com/postfinance/kubenurse/internal/servicecheck"
 	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
@@ -15,13 +19,16 @@ func TestCombined(t *testing.T) {
 
 	os.Setenv("KUBENURSE_EXTRA_CHECKS", "cloudy_endpoint:http://cloudy.enpdoint:1234/test|ep_number_two:http://interesting.endpoint:8080/abcd")
 	fakeClient := fake.NewFakeClient()
-	kubenurse, err := New(fakeClient)
+	cfg, err := config.Load("")
+	r.NoError(err)
+
+	kubenurse, err := New(fakeClient, cfg)
 	r.NoError(err)
 	r.NotNil(kubenurse)
 
-	r.Equal(map[string]string{
-		"ep_number_two":   "http://interesting.endpoint:8080/abcd",
-		"cloudy_endpoint": "http://cloudy.enpdoint:1234/test",
+	r.Equal(map[string]servicecheck.ExtraCheck{
+		"ep_number_two":   {URL: "http://interesting.endpoint:8080/abcd"},
+		"cloudy_endpoint": {URL: "http://cloudy.enpdoint:1234/test"},
 	}, kubenurse.checker.ExtraChecks)
 
 	t.Run("start/stop", func(t *testing.T) {
@@ -46,3 +53,33 @@ func TestCombined(t *testing.T) {
 		r.NoError(err)
 	})
 }
+
+func TestNodeReadinessWatcher(t *testing.T) {
+	r := require.New(t)
+
+	t.Setenv("KUBENURSE_EXTRA_CHECKS", "")
+
+	// there is no kubenurse pod, the node is the configured one
+	fakeClient := fake.NewFakeClient(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+		Spec:       v1.NodeSpec{Unschedulable: true},
+	})
+
+	cfg, err := config.Load("")
+	r.NoError(err)
+
+	cfg.Checker.NodeName = "node-a"
+
+	kubenurse, err := New(fakeClient, cfg)
+	r.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kubenurse.StartNodeReadinessWatcher(ctx, fakeClient)
+
+	r.Eventually(func() bool {
+		nodeName := kubenurse.nodeName.Load()
+		return nodeName != nil && *nodeName == "node-a" && !kubenurse.nodeReady.Load()
+	}, 3*time.Second, 10*time.Millisecond)
+}
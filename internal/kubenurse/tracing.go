@@ -0,0 +1,56 @@
+package kubenurse
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/postfinance/kubenurse/internal/config"
+	"github.com/postfinance/kubenurse/internal/servicecheck"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTracerProvider creates the provider of the tracer of the checks, which
+// exports the spans in batches to the OTLP/HTTP collector of cfg.
+func newTracerProvider(cfg *config.Config) (*sdktrace.TracerProvider, error) {
+	endpoint, err := otlpURL(cfg.Tracing.Endpoint, "v1/traces")
+	if err != nil {
+		return nil, fmt.Errorf("parse the tracing endpoint: %w", err)
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("create the otlp trace exporter: %w", err)
+	}
+
+	res, err := newResource(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)), nil
+}
+
+// traceRequest starts the server span of a request of another kubenurse,
+// which propagated its trace context. The returned span doesn't record
+// anything for the other requests, or if the tracing is disabled.
+func (s *Server) traceRequest(r *http.Request) trace.Span {
+	ctx := propagation.TraceContext{}.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	if s.tracerProvider == nil || !trace.SpanContextFromContext(ctx).IsRemote() {
+		return trace.SpanFromContext(ctx)
+	}
+
+	_, span := s.tracerProvider.Tracer(servicecheck.TracerName).Start(ctx, r.Method+" "+r.URL.Path,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLPath(r.URL.Path),
+		),
+	)
+
+	return span
+}
@@ -0,0 +1,145 @@
+package kubenurse
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/postfinance/kubenurse/internal/config"
+	"github.com/postfinance/kubenurse/internal/servicecheck"
+	"github.com/stretchr/testify/require"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// collectorStub is an OTLP/HTTP collector which keeps the received spans.
+type collectorStub struct {
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func (c *collectorStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/traces" {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, rs := range req.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			c.spans = append(c.spans, ss.GetSpans()...)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+}
+
+// received returns a copy of the received spans.
+func (c *collectorStub) received() []*tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slices.Clone(c.spans)
+}
+
+func TestTracing(t *testing.T) {
+	r := require.New(t)
+
+	collector := &collectorStub{}
+
+	otlp := httptest.NewServer(collector)
+	defer otlp.Close()
+
+	t.Setenv("KUBENURSE_TRACING_ENDPOINT", otlp.URL)
+	t.Setenv("KUBENURSE_EXTRA_CHECKS", "") // set by TestCombined
+
+	cfg, err := config.Load("")
+	r.NoError(err)
+
+	kubenurse, err := New(fake.NewFakeClient(), cfg)
+	r.NoError(err)
+
+	ts := httptest.NewServer(kubenurse.http.Handler)
+	defer ts.Close()
+
+	// an endpoint which isn't a kubenurse
+	var externalTraceparent atomic.Value
+
+	external := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		externalTraceparent.Store(r.Header.Get("traceparent"))
+	}))
+	defer external.Close()
+
+	// only the me_service check runs, against the /alwayshappy endpoint of
+	// the kubenurse itself, and an extra check against the external endpoint
+	chk := kubenurse.checker
+	chk.KubenurseServiceURL = ts.URL
+	chk.ExtraChecks = map[string]servicecheck.ExtraCheck{"external": {URL: external.URL}}
+	chk.SkipCheckMeIngress = true
+	chk.SkipCheckAPIServerDirect = true
+	chk.SkipCheckAPIServerDNS = true
+	chk.SkipCheckNeighbourhood = true
+
+	chk.Run(context.Background())
+
+	r.NoError(kubenurse.tracerProvider.ForceFlush(context.Background()))
+
+	spans := collector.received()
+
+	// child returns the span with the given name whose parent is parent, the
+	// names alone aren't unique, e.g. every http request has a connect span
+	child := func(parent *tracepb.Span, name string) *tracepb.Span {
+		idx := slices.IndexFunc(spans, func(s *tracepb.Span) bool {
+			return s.GetName() == name && bytes.Equal(s.GetTraceId(), parent.GetTraceId()) &&
+				bytes.Equal(s.GetParentSpanId(), parent.GetSpanId())
+		})
+		r.GreaterOrEqual(idx, 0, "%s must have a %s child", parent.GetName(), name)
+
+		return spans[idx]
+	}
+
+	idx := slices.IndexFunc(spans, func(s *tracepb.Span) bool { return s.GetName() == "run" })
+	r.GreaterOrEqual(idx, 0, "the run must have a span")
+
+	run := spans[idx]
+	r.Empty(run.GetParentSpanId())
+
+	meService := child(run, "me_service")
+	get := child(meService, "HTTP GET")
+	child(get, "connect")
+	child(get, "wait_first_byte")
+
+	// the server span is recorded from the propagated traceparent
+	server := child(get, "GET /alwayshappy")
+	r.Equal(tracepb.Span_SPAN_KIND_SERVER, server.GetKind())
+	r.Equal(tracepb.Span_SPAN_KIND_CLIENT, get.GetKind())
+	r.Equal(tracepb.Status_STATUS_CODE_UNSET, meService.GetStatus().GetCode())
+
+	// the trace context is only propagated to the kubenurses
+	child(child(run, "external"), "HTTP GET")
+	r.Equal("", externalTraceparent.Load(), "the external endpoint must be checked")
+
+	r.NoError(kubenurse.tracerProvider.Shutdown(context.Background()))
+}
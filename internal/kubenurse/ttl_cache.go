@@ -54,3 +54,17 @@ func (c *TTLCache[K]) cleanupExpired() {
 		}
 	}
 }
+
+func (c *TTLCache[K]) ActiveKeys() []K {
+	c.cleanupExpired()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]K, 0, len(c.m))
+	for k := range c.m {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
@@ -0,0 +1,159 @@
+package servicecheck
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	neighbourLossRatio  = "neighbour_loss_ratio"
+	neighbourRTTSec     = "neighbour_rtt_seconds"
+	neighbourRTTMeanSec = "neighbour_rtt_mean_seconds"
+	neighbourJitterSec  = "neighbour_jitter_seconds"
+)
+
+//nolint:gochecknoglobals // read-only list
+var neighbourRTTQuantiles = []float64{0.5, 0.9, 0.99}
+
+// probeStats summarizes the results of a burst of probes.
+type probeStats struct {
+	sent int
+	// rtts of the successful probes, in the order in which they were sent
+	rtts []time.Duration
+}
+
+// lossRatio returns the ratio of failed probes, between 0 and 1.
+func (s *probeStats) lossRatio() float64 {
+	if s.sent == 0 {
+		return 0
+	}
+
+	return float64(s.sent-len(s.rtts)) / float64(s.sent)
+}
+
+func (s *probeStats) mean() time.Duration {
+	if len(s.rtts) == 0 {
+		return 0
+	}
+
+	var sum time.Duration
+	for _, rtt := range s.rtts {
+		sum += rtt
+	}
+
+	return sum / time.Duration(len(s.rtts))
+}
+
+// quantile returns the q-quantile of the rtts, with the nearest-rank method.
+func (s *probeStats) quantile(q float64) time.Duration {
+	if len(s.rtts) == 0 {
+		return 0
+	}
+
+	sorted := slices.Clone(s.rtts)
+	slices.Sort(sorted)
+
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+// jitter returns the mean absolute difference between the rtts of two
+// consecutive successful probes, as the interarrival jitter of RFC 3550.
+func (s *probeStats) jitter() time.Duration {
+	if len(s.rtts) < 2 {
+		return 0
+	}
+
+	var sum time.Duration
+
+	for i := 1; i < len(s.rtts); i++ {
+		d := s.rtts[i] - s.rtts[i-1]
+		sum += max(d, -d)
+	}
+
+	return sum / time.Duration(len(s.rtts)-1)
+}
+
+// doNeighbourBurst sends NeighbourBurstProbes requests to the url of a
+// neighbour, NeighbourBurstGap apart, and exposes the loss ratio, the mean
+// and quantiles of the round-trip times and the jitter of the burst as gauges
+// labelled with the source and destination nodes, until the neighbour isn't
+// checked anymore. Every request is still
+// instrumented like a single neighbour request. The probes don't wait for
+// each other, so the burst lasts at most the gaps and one request timeout,
+// even if the neighbour doesn't answer. The check only fails if all the
+// probes failed, with the result of the last one.
+func (c *Checker) doNeighbourBurst(ctx context.Context, url, dstNode string) string {
+	var (
+		wg sync.WaitGroup
+		// results and rtts of the probes, in the order in which they are sent
+		results = make([]string, c.NeighbourBurstProbes)
+		rtts    = make([]time.Duration, c.NeighbourBurstProbes)
+		gap     = c.NeighbourBurstGap
+	)
+
+	for i := range c.NeighbourBurstProbes {
+		wg.Go(func() {
+			select {
+			case <-time.After(time.Duration(i) * gap):
+			case <-ctx.Done():
+				results[i] = ctx.Err().Error()
+				return
+			}
+
+			// the errors of every probe must be accounted separately
+			pctx := context.WithValue(ctx, kubenurseErrorAccountedKey{}, &atomic.Bool{})
+			start := time.Now()
+
+			if results[i] = c.doRequest(pctx, url, true); results[i] == okStr {
+				rtts[i] = time.Since(start)
+			}
+		})
+	}
+
+	wg.Wait()
+
+	stats := probeStats{sent: c.NeighbourBurstProbes}
+	res := okStr
+
+	for i := range results {
+		if results[i] == okStr {
+			stats.rtts = append(stats.rtts, rtts[i])
+		} else {
+			res = results[i]
+		}
+	}
+
+	l := append([]string{"src_node", c.currentNode(), "dst_node", dstNode}, metricLabels(ctx)...)
+
+	c.pairGauge(dstNode, neighbourLossRatio, l...).Set(stats.lossRatio())
+
+	if len(stats.rtts) == 0 {
+		// there is no round-trip time, the values of the last burst would be
+		// exported as the current ones
+		c.dropPairSeries(dstNode, neighbourRTTMeanSec, l...)
+		c.dropPairSeries(dstNode, neighbourJitterSec, l...)
+
+		for _, q := range neighbourRTTQuantiles {
+			c.dropPairSeries(dstNode, neighbourRTTSec, append(l, "quantile", strconv.FormatFloat(q, 'f', -1, 64))...)
+		}
+
+		return res
+	}
+
+	c.pairGauge(dstNode, neighbourRTTMeanSec, l...).Set(stats.mean().Seconds())
+	c.pairGauge(dstNode, neighbourJitterSec, l...).Set(stats.jitter().Seconds())
+
+	for _, q := range neighbourRTTQuantiles {
+		c.pairGauge(dstNode, neighbourRTTSec,
+			append(l, "quantile", strconv.FormatFloat(q, 'f', -1, 64))...).Set(stats.quantile(q).Seconds())
+	}
+
+	return okStr
+}
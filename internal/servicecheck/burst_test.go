@@ -0,0 +1,132 @@
+package servicecheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestProbeStats(t *testing.T) {
+	r := require.New(t)
+
+	ms := time.Millisecond
+	stats := probeStats{sent: 5, rtts: []time.Duration{10 * ms, 30 * ms, 20 * ms, 40 * ms}}
+
+	r.InDelta(0.2, stats.lossRatio(), 1e-9)
+	r.Equal(25*ms, stats.mean())
+	r.Equal(20*ms, stats.quantile(0.5))
+	r.Equal(40*ms, stats.quantile(0.9))
+	r.Equal(10*ms, stats.quantile(0))
+	r.Equal(50*ms/3, stats.jitter()) // (20 + 10 + 20) / 3
+
+	empty := probeStats{sent: 3}
+	r.InDelta(1, empty.lossRatio(), 1e-9)
+	r.Zero(empty.mean())
+	r.Zero(empty.quantile(0.99))
+	r.Zero(empty.jitter())
+}
+
+func TestNeighbourBurst(t *testing.T) {
+	r := require.New(t)
+
+	var requests atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1)%2 == 0 { // every other probe fails
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	checker, err := New(fake.NewFakeClient(), false, 3*time.Second, func(s string) Histogram {
+		return metrics.GetOrCreatePrometheusHistogram(s)
+	})
+	r.NoError(err)
+
+	checker.NeighbourBurstProbes = 4
+	checker.NeighbourBurstGap = time.Millisecond
+	checker.NodeName = "src"
+
+	ctx := checker.withDstNode(context.WithValue(context.Background(), kubenurseTypeKey{}, "path_dst"), "dst")
+
+	r.Equal(okStr, checker.doNeighbourBurst(ctx, server.URL+"/alwayshappy", "dst"))
+	r.Equal(int64(4), requests.Load())
+
+	var sb strings.Builder
+
+	metrics.WritePrometheus(&sb, false)
+	r.Contains(sb.String(), `kubenurse_neighbour_loss_ratio{src_node="src",dst_node="dst"} 0.5`)
+	r.Contains(sb.String(), `kubenurse_neighbour_rtt_seconds{src_node="src",dst_node="dst",quantile="0.99"}`)
+	r.Contains(sb.String(), `kubenurse_neighbour_jitter_seconds{src_node="src",dst_node="dst"}`)
+	r.Contains(sb.String(), `kubenurse_errors_total{type="path_dst",event="status_code_503"} 2`)
+
+	t.Run("neighbour not checked anymore", func(t *testing.T) {
+		r := require.New(t)
+
+		checker.pairSeries.sweep() // the run which checked dst
+		r.Contains(metrics.ListMetricNames(), `kubenurse_neighbour_loss_ratio{src_node="src",dst_node="dst"}`)
+
+		checker.pairSeries.sweep() // a run which didn't check dst
+		r.NotContains(metrics.ListMetricNames(), `kubenurse_neighbour_loss_ratio{src_node="src",dst_node="dst"}`)
+		r.NotContains(metrics.ListMetricNames(), `kubenurse_neighbour_rtt_seconds{src_node="src",dst_node="dst",quantile="0.99"}`)
+		r.NotContains(metrics.ListMetricNames(), `kubenurse_errors_total{type="path_dst",event="status_code_503"}`)
+	})
+
+	t.Run("slow neighbour", func(t *testing.T) {
+		r := require.New(t)
+
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer slow.Close()
+
+		// the probes don't wait for each other
+		start := time.Now()
+
+		r.Equal(okStr, checker.doNeighbourBurst(ctx, slow.URL+"/alwayshappy", "slow"))
+		r.Less(time.Since(start), 4*200*time.Millisecond)
+	})
+
+	t.Run("all probes fail", func(t *testing.T) {
+		r := require.New(t)
+
+		var failing atomic.Bool
+
+		flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if failing.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer flaky.Close()
+
+		r.Equal(okStr, checker.doNeighbourBurst(ctx, flaky.URL+"/alwayshappy", "flaky"))
+		r.Contains(metrics.ListMetricNames(), `kubenurse_neighbour_rtt_mean_seconds{src_node="src",dst_node="flaky"}`)
+
+		// the round-trip times of the previous burst aren't exported anymore
+		failing.Store(true)
+		r.NotEqual(okStr, checker.doNeighbourBurst(ctx, flaky.URL+"/alwayshappy", "flaky"))
+		r.Contains(metrics.ListMetricNames(), `kubenurse_neighbour_loss_ratio{src_node="src",dst_node="flaky"}`)
+		r.NotContains(metrics.ListMetricNames(), `kubenurse_neighbour_rtt_mean_seconds{src_node="src",dst_node="flaky"}`)
+		r.NotContains(metrics.ListMetricNames(), `kubenurse_neighbour_jitter_seconds{src_node="src",dst_node="flaky"}`)
+		r.NotContains(metrics.ListMetricNames(), `kubenurse_neighbour_rtt_seconds{src_node="src",dst_node="flaky",quantile="0.5"}`)
+
+		server.Close()
+
+		r.NotEqual(okStr, checker.doNeighbourBurst(ctx, server.URL+"/alwayshappy", "closed"))
+	})
+}
@@ -0,0 +1,189 @@
+package servicecheck
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+//nolint:gochecknoglobals // used during testing
+var interfaceAddrs = net.InterfaceAddrs
+
+// NeighbourDiscovery finds the neighbours checked on every run.
+type NeighbourDiscovery interface {
+	Neighbours(ctx context.Context) ([]*Neighbour, error)
+}
+
+// Neighbours returns the neighbours found by the configured discovery, or the
+// kubenurse pods if there is none.
+func (c *Checker) Neighbours(ctx context.Context) ([]*Neighbour, error) {
+	if c.Discovery != nil {
+		return c.Discovery.Neighbours(ctx)
+	}
+
+	return c.getNeighbours(ctx, c.KubenurseNamespace, c.NeighbourFilter)
+}
+
+// StaticPeers are neighbours at fixed host[:port] addresses, used to run
+// kubenurse outside of Kubernetes.
+type StaticPeers []string
+
+// Neighbours implements NeighbourDiscovery.
+func (p StaticPeers) Neighbours(_ context.Context) ([]*Neighbour, error) {
+	return peerNeighbours(p)
+}
+
+// PeersFile is a file with a host[:port] per line, empty lines and comments
+// starting with # are ignored. It is read on every run, so that the peers
+// can be changed without a restart.
+type PeersFile string
+
+// Neighbours implements NeighbourDiscovery.
+func (f PeersFile) Neighbours(_ context.Context) ([]*Neighbour, error) {
+	b, err := os.ReadFile(string(f))
+	if err != nil {
+		return nil, fmt.Errorf("read peers file: %w", err)
+	}
+
+	var peers []string
+
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			peers = append(peers, line)
+		}
+	}
+
+	return peerNeighbours(peers)
+}
+
+// DNSPeers resolves the neighbours from the SRV records of Name if it starts
+// with an underscore, e.g. _kubenurse._tcp.example.com, else from its A and
+// AAAA records.
+type DNSPeers struct {
+	Name     string
+	Resolver *net.Resolver
+}
+
+// Neighbours implements NeighbourDiscovery.
+func (d *DNSPeers) Neighbours(ctx context.Context) ([]*Neighbour, error) {
+	resolver := d.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	var peers []string
+
+	if strings.HasPrefix(d.Name, "_") {
+		_, srvs, err := resolver.LookupSRV(ctx, "", "", d.Name)
+		if err != nil {
+			return nil, fmt.Errorf("lookup peers: %w", err)
+		}
+
+		for _, srv := range srvs {
+			peers = append(peers, net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port))))
+		}
+	} else {
+		addrs, err := resolver.LookupHost(ctx, d.Name)
+		if err != nil {
+			return nil, fmt.Errorf("lookup peers: %w", err)
+		}
+
+		peers = addrs
+	}
+
+	return peerNeighbours(peers)
+}
+
+// peerNeighbours converts the host[:port] addresses of the peers to
+// neighbours named after the addresses. The peers which are the kubenurse
+// itself are skipped, see isSelf.
+func peerNeighbours(peers []string) ([]*Neighbour, error) {
+	hostname, _ := osHostname()
+	local := localAddrs()
+
+	neighbours := make([]*Neighbour, 0, len(peers))
+
+	for _, peer := range peers {
+		host, port, err := splitPeer(peer)
+		if err != nil {
+			return nil, err
+		}
+
+		name := host
+		if port != 0 {
+			name = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+
+		if isSelf(host, hostname, local) || slices.ContainsFunc(neighbours, func(n *Neighbour) bool { return n.NodeName == name }) {
+			continue
+		}
+
+		neighbours = append(neighbours, &Neighbour{
+			PodName:  name,
+			PodIP:    host,
+			NodeName: name,
+			NodeHash: sha256Uint64(name),
+			Port:     port,
+		})
+	}
+
+	return neighbours, nil
+}
+
+// isSelf reports whether the host of a peer is the kubenurse itself, i.e. one
+// of the local addresses, its hostname, or the hostname with or without the
+// domain.
+func isSelf(host, hostname string, local map[netip.Addr]bool) bool {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return local[addr.Unmap().WithZone("")]
+	}
+
+	if hostname == "" {
+		return false
+	}
+
+	shortHost, _, _ := strings.Cut(host, ".")
+	shortHostname, _, _ := strings.Cut(hostname, ".")
+
+	return strings.EqualFold(host, hostname) ||
+		strings.EqualFold(shortHost, hostname) ||
+		strings.EqualFold(host, shortHostname)
+}
+
+// localAddrs returns the addresses of the network interfaces.
+func localAddrs() map[netip.Addr]bool {
+	addrs, _ := interfaceAddrs()
+	local := make(map[netip.Addr]bool, len(addrs))
+
+	for _, a := range addrs {
+		if prefix, err := netip.ParsePrefix(a.String()); err == nil {
+			local[prefix.Addr().Unmap()] = true
+		}
+	}
+
+	return local
+}
+
+// splitPeer splits a host[:port] address, the port is zero if it is missing.
+func splitPeer(peer string) (host string, port int, err error) {
+	host, p, err := net.SplitHostPort(peer)
+	if err != nil { // no port
+		return strings.Trim(peer, "[]"), 0, nil
+	}
+
+	port, err = strconv.Atoi(p)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port in peer %q", peer)
+	}
+
+	return host, port, nil
+}
@@ -0,0 +1,69 @@
+package servicecheck
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerDiscovery(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	osHostname = func() (string, error) { return "edge-1", nil }
+	defer func() { osHostname = os.Hostname }()
+
+	interfaceAddrs = func() ([]net.Addr, error) {
+		return []net.Addr{&net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(24, 32)}}, nil
+	}
+	defer func() { interfaceAddrs = net.InterfaceAddrs }()
+
+	names := func(neighbours []*Neighbour) []string {
+		out := make([]string, 0, len(neighbours))
+		for _, n := range neighbours {
+			out = append(out, n.NodeName)
+		}
+
+		return out
+	}
+
+	neighbours, err := StaticPeers{
+		"edge-1", "edge-2", "10.0.0.3:9090", "[fd00::4]:8080", "fd00::5", "edge-2",
+		// the kubenurse itself, by its address and its domain name
+		"10.0.0.1:8080", "edge-1.example.com",
+	}.Neighbours(ctx)
+	r.NoError(err)
+	r.Equal([]string{"edge-2", "10.0.0.3:9090", "[fd00::4]:8080", "fd00::5"}, names(neighbours))
+	r.Equal("http://edge-2:8080/alwayshappy", neighbours[0].url(false))
+	r.Equal("https://10.0.0.3:9090/alwayshappy", neighbours[1].url(true))
+	r.Equal("http://[fd00::4]:8080/alwayshappy", neighbours[2].url(false))
+	r.Equal("https://[fd00::5]:8443/alwayshappy", neighbours[3].url(true))
+
+	_, err = StaticPeers{"edge-2:http"}.Neighbours(ctx)
+	r.EqualError(err, `invalid port in peer "edge-2:http"`)
+
+	path := filepath.Join(t.TempDir(), "peers")
+	r.NoError(os.WriteFile(path, []byte("# the edge fleet\nedge-1\n\n  edge-2 # rack 2\nedge-3:8081\n"), 0o600))
+
+	neighbours, err = PeersFile(path).Neighbours(ctx)
+	r.NoError(err)
+	r.Equal([]string{"edge-2", "edge-3:8081"}, names(neighbours))
+
+	_, err = PeersFile(filepath.Join(t.TempDir(), "missing")).Neighbours(ctx)
+	r.ErrorContains(err, "read peers file")
+
+	neighbours, err = (&DNSPeers{Name: "localhost"}).Neighbours(ctx)
+	r.NoError(err)
+	r.Contains(names(neighbours), "127.0.0.1")
+
+	// with the addresses of the actual network interfaces
+	interfaceAddrs = net.InterfaceAddrs
+
+	neighbours, err = StaticPeers{"127.0.0.1:8080", "edge-2"}.Neighbours(ctx)
+	r.NoError(err)
+	r.Equal([]string{"edge-2"}, names(neighbours))
+}
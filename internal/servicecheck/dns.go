@@ -0,0 +1,309 @@
+package servicecheck
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/postfinance/kubenurse/internal/util"
+	"golang.org/x/net/dns/dnsmessage"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	dnsReqDurSec     = "dns_request_duration_seconds"
+	dnsResponseTotal = "dns_responses_total"
+	dnsPort          = "53"
+	resolvConfSource = "resolv.conf"
+)
+
+//nolint:gochecknoglobals // used during testing
+var resolvConfPath = "/etc/resolv.conf"
+
+// DNSQuery is a name which the DNS check resolves against every DNS server.
+type DNSQuery struct {
+	Name string
+	// Type is A, AAAA or SRV
+	Type string
+}
+
+// dnsServer is a DNS server queried by the DNS check.
+type dnsServer struct {
+	ip   string
+	port string
+	// source is the <namespace>/<name> of the cluster DNS service, or resolv.conf
+	source string
+}
+
+// runDNSChecks queries the DNS names against every cluster DNS endpoint and
+// every nameserver of resolv.conf, so that a single broken DNS replica can be
+// found. It returns false if the DNS servers couldn't be listed.
+func (c *Checker) runDNSChecks(ctx context.Context, wg *sync.WaitGroup, res *sync.Map) bool {
+	servers, err := c.dnsServers(ctx)
+	if err != nil {
+		res.Store(DNSState, newResult(err.Error()))
+		return false
+	}
+
+	res.Store(DNSState, newResult(okStr))
+
+	wg.Add(len(servers))
+
+	for _, srv := range servers {
+		go c.measure(c.withDNSServer(ctx, srv.ip), wg, res, func(ctx context.Context) string {
+			return c.checkDNSServer(ctx, srv)
+		}, "dns_"+srv.ip)
+	}
+
+	return true
+}
+
+// dnsServers returns the ready endpoints of the cluster DNS service, followed
+// by the nameservers of resolv.conf.
+func (c *Checker) dnsServers(ctx context.Context) ([]dnsServer, error) {
+	esl := discoveryv1.EndpointSliceList{}
+	if err := c.client.List(ctx, &esl,
+		client.InNamespace(c.DNSServiceNamespace),
+		client.MatchingLabels{discoveryv1.LabelServiceName: c.DNSServiceName},
+	); err != nil {
+		return nil, fmt.Errorf("list dns endpointslices: %w", err)
+	}
+
+	var (
+		servers []dnsServer
+		seen    = make(map[string]bool)
+		source  = c.DNSServiceNamespace + "/" + c.DNSServiceName
+	)
+
+	for i := range esl.Items {
+		es := &esl.Items[i]
+		port := dnsPort
+
+		for _, p := range es.Ports {
+			if p.Port != nil && (p.Protocol == nil || *p.Protocol == v1.ProtocolUDP) {
+				port = strconv.Itoa(int(*p.Port))
+				break
+			}
+		}
+
+		for _, ep := range es.Endpoints {
+			if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+				continue
+			}
+
+			for _, ip := range ep.Addresses {
+				if !seen[ip] {
+					seen[ip] = true
+
+					servers = append(servers, dnsServer{ip: ip, port: port, source: source})
+				}
+			}
+		}
+	}
+
+	f, err := os.Open(resolvConfPath)
+	if err != nil {
+		slog.Error("cannot read nameservers", "file", resolvConfPath, "err", err)
+		return servers, nil
+	}
+	defer f.Close()
+
+	for _, ip := range parseResolvConf(f) {
+		if !seen[ip] {
+			seen[ip] = true
+
+			servers = append(servers, dnsServer{ip: ip, port: dnsPort, source: resolvConfSource})
+		}
+	}
+
+	return servers, nil
+}
+
+// parseResolvConf returns the nameservers of a resolv.conf file.
+func parseResolvConf(r io.Reader) []string {
+	var nameservers []string
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+			nameservers = append(nameservers, fields[1])
+		}
+	}
+
+	return nameservers
+}
+
+// checkDNSServer resolves all the DNS queries against srv. It returns ok if
+// all the queries were answered, and the first error otherwise.
+func (c *Checker) checkDNSServer(ctx context.Context, srv dnsServer) string {
+	requestType, _ := ctx.Value(kubenurseTypeKey{}).(string)
+	addr := net.JoinHostPort(srv.ip, srv.port)
+	res := okStr
+
+	recorderFrom(ctx).startRequest(addr)
+
+	for _, q := range c.DNSQueries {
+		if err := c.resolve(ctx, requestType, addr, srv.source, q); err != nil && res == okStr {
+			res = err.Error()
+		}
+	}
+
+	return res
+}
+
+func (c *Checker) resolve(ctx context.Context, requestType, addr, source string, q DNSQuery) error {
+	qtype, err := dnsType(q.Type)
+	if err != nil {
+		return err
+	}
+
+	fqdn := q.Name
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return fmt.Errorf("invalid dns name %q: %w", q.Name, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.DNSTimeout)
+	defer cancel()
+
+	start := time.Now()
+	rcode, answers, err := dnsExchange(ctx, addr, dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET})
+
+	l := []string{"type", requestType}
+
+	var event string
+
+	switch {
+	case err != nil:
+		event = "round_trip_error"
+	case rcode != dnsmessage.RCodeSuccess:
+		event = strings.ToLower(rcodeName(rcode))
+		err = fmt.Errorf("%s %s: %s", q.Type, q.Name, rcodeName(rcode))
+	case answers == 0:
+		event = "no_answer"
+		err = fmt.Errorf("%s %s: no answer", q.Type, q.Name)
+	}
+
+	if event != "round_trip_error" { // a response was received
+		metrics.GetOrCreateCounter(trackSeries(ctx, util.GenMetricsName(dnsResponseTotal,
+			"server", addr, "source", source, "rcode", rcodeName(rcode)))).Inc()
+	}
+
+	if err != nil {
+		recorderFrom(ctx).fail(event)
+		metrics.GetOrCreateCounter(trackSeries(ctx, util.GenMetricsName(errCounter, append(l, "event", event)...))).Inc()
+		slog.Error("request failure in dns check", "event_type", event, "request_type", requestType,
+			"server", addr, "name", q.Name, "qtype", q.Type, "err", err)
+
+		return err
+	}
+
+	c.histogramGetter(trackSeries(ctx, util.GenMetricsName(dnsReqDurSec,
+		"server", addr, "source", source, "name", q.Name, "qtype", q.Type))).UpdateDuration(start)
+
+	return nil
+}
+
+// dnsExchange sends the question to addr over UDP and returns the response
+// code and the number of answers.
+func dnsExchange(ctx context.Context, addr string, q dnsmessage.Question) (dnsmessage.RCode, int, error) {
+	id := uint16(rand.N(1 << 16)) //nolint:gosec // not used for security purposes
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	if err := b.StartQuestions(); err != nil {
+		return 0, 0, err
+	}
+
+	if err := b.Question(q); err != nil {
+		return 0, 0, err
+	}
+
+	msg, err := b.Finish()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	dialer := net.Dialer{}
+
+	conn, err := dialer.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(msg); err != nil {
+		return 0, 0, err
+	}
+
+	buf := make([]byte, 4096)
+
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		var p dnsmessage.Parser
+
+		h, err := p.Start(buf[:n])
+		if err != nil || h.ID != id || !h.Response { // ignore unrelated or malformed datagrams
+			continue
+		}
+
+		if err := p.SkipAllQuestions(); err != nil {
+			return h.RCode, 0, err
+		}
+
+		answers, err := p.AllAnswers()
+		if err != nil && !errors.Is(err, dnsmessage.ErrSectionDone) {
+			return h.RCode, 0, err
+		}
+
+		return h.RCode, len(answers), nil
+	}
+}
+
+func dnsType(t string) (dnsmessage.Type, error) {
+	switch t {
+	case "A":
+		return dnsmessage.TypeA, nil
+	case "AAAA":
+		return dnsmessage.TypeAAAA, nil
+	case "SRV":
+		return dnsmessage.TypeSRV, nil
+	default:
+		return 0, fmt.Errorf("unsupported dns query type %q", t)
+	}
+}
+
+// rcodeName returns the usual name of a DNS response code, e.g. NXDOMAIN.
+func rcodeName(rcode dnsmessage.RCode) string {
+	names := []string{"NOERROR", "FORMERR", "SERVFAIL", "NXDOMAIN", "NOTIMP", "REFUSED"}
+	if int(rcode) < len(names) {
+		return names[rcode]
+	}
+
+	return "RCODE" + strconv.Itoa(int(rcode))
+}
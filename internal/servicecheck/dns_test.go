@@ -0,0 +1,174 @@
+package servicecheck
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// startDNSServer starts a UDP DNS server on ip, which answers the A record of
+// known, NXDOMAIN for other names, or always rcode if it isn't RCodeSuccess.
+func startDNSServer(t *testing.T, ip, known string, rcode dnsmessage.RCode) int32 {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", net.JoinHostPort(ip, "0"))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var p dnsmessage.Parser
+
+			h, err := p.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+
+			q, err := p.Question()
+			if err != nil {
+				continue
+			}
+
+			h.Response = true
+			h.RCode = rcode
+
+			if rcode == dnsmessage.RCodeSuccess && q.Name.String() != known {
+				h.RCode = dnsmessage.RCodeNameError
+			}
+
+			b := dnsmessage.NewBuilder(nil, h)
+			_ = b.StartQuestions()
+			_ = b.Question(q)
+
+			if h.RCode == dnsmessage.RCodeSuccess {
+				_ = b.StartAnswers()
+				_ = b.AResource(dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 30},
+					dnsmessage.AResource{A: [4]byte{10, 96, 0, 1}})
+			}
+
+			msg, _ := b.Finish()
+			_, _ = conn.WriteTo(msg, addr)
+		}
+	}()
+
+	return int32(conn.LocalAddr().(*net.UDPAddr).Port) //nolint:gosec // a port always fits
+}
+
+func TestDNSCheck(t *testing.T) {
+	r := require.New(t)
+
+	const known = "kubernetes.default.svc.cluster.local."
+
+	healthyPort := startDNSServer(t, "127.0.0.1", known, dnsmessage.RCodeSuccess)
+	brokenPort := startDNSServer(t, "127.0.0.2", known, dnsmessage.RCodeServerFailure)
+
+	newSlice := func(name, ip string, port int32) *discoveryv1.EndpointSlice {
+		return &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "kube-system",
+				Name:      name,
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "kube-dns"},
+			},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints: []discoveryv1.Endpoint{{
+				Addresses:  []string{ip},
+				Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(true)},
+			}},
+			Ports: []discoveryv1.EndpointPort{{
+				Name:     ptr.To("dns"),
+				Protocol: ptr.To(v1.ProtocolUDP),
+				Port:     ptr.To(port),
+			}},
+		}
+	}
+
+	fakeClient := fake.NewClientBuilder().WithObjects(
+		newSlice("kube-dns-healthy", "127.0.0.1", healthyPort),
+		newSlice("kube-dns-broken", "127.0.0.2", brokenPort),
+	).Build()
+
+	resolvConfPath = filepath.Join(t.TempDir(), "missing")
+	defer func() { resolvConfPath = "/etc/resolv.conf" }()
+
+	checker, err := New(fakeClient, false, 3*time.Second, func(s string) Histogram {
+		return metrics.GetOrCreatePrometheusHistogram(s)
+	})
+	r.NoError(err)
+
+	checker.SkipCheckAPIServerDNS = true
+	checker.SkipCheckAPIServerDirect = true
+	checker.SkipCheckMeIngress = true
+	checker.SkipCheckMeService = true
+	checker.SkipCheckNeighbourhood = true
+
+	checker.CheckDNS = true
+	checker.DNSServiceNamespace = "kube-system"
+	checker.DNSServiceName = "kube-dns"
+	checker.DNSQueries = []DNSQuery{{Name: strings.TrimSuffix(known, "."), Type: "A"}}
+
+	checker.Run(context.Background())
+
+	r.Equal(StatusOK, checker.LastResults().Checks[DNSState].Status)
+	r.Equal(StatusOK, checker.LastResults().Checks["dns_127.0.0.1"].Status)
+	r.Contains(checker.LastResults().Checks["dns_127.0.0.2"].Message, "SERVFAIL")
+
+	healthy := "127.0.0.1:" + strconv.Itoa(int(healthyPort))
+
+	var sb strings.Builder
+
+	metrics.WritePrometheus(&sb, false)
+	r.Contains(sb.String(), `kubenurse_errors_total{type="dns_127.0.0.2",event="servfail"} 1`)
+	r.Contains(sb.String(), `kubenurse_dns_responses_total{server="`+healthy+`",source="kube-system/kube-dns",rcode="NOERROR"} 1`)
+	r.Contains(sb.String(), `kubenurse_dns_request_duration_seconds_count{server="`+healthy+
+		`",source="kube-system/kube-dns",name="kubernetes.default.svc.cluster.local",qtype="A"} 1`)
+
+	t.Run("unknown name", func(t *testing.T) {
+		checker.DNSQueries = []DNSQuery{{Name: "missing.cluster.local", Type: "A"}}
+		checker.Run(context.Background())
+
+		require.Contains(t, checker.LastResults().Checks["dns_127.0.0.1"].Message, "NXDOMAIN")
+	})
+
+	t.Run("rescheduled server", func(t *testing.T) {
+		r := require.New(t)
+
+		r.NoError(fakeClient.Delete(context.Background(), newSlice("kube-dns-broken", "127.0.0.2", brokenPort)))
+		checker.Run(context.Background())
+
+		r.NotContains(checker.LastResults().Checks, "dns_127.0.0.2")
+		r.NotContains(metrics.ListMetricNames(), `kubenurse_errors_total{type="dns_127.0.0.2",event="servfail"}`)
+		r.Contains(metrics.ListMetricNames(), `kubenurse_errors_total{type="dns_127.0.0.1",event="nxdomain"}`)
+	})
+}
+
+func TestParseResolvConf(t *testing.T) {
+	resolvConf := `# generated by the kubelet
+search kubenurse.svc.cluster.local svc.cluster.local cluster.local
+nameserver 10.96.0.10
+nameserver fd00::10
+nameserver invalid
+options ndots:5
+`
+
+	require.Equal(t, []string{"10.96.0.10", "fd00::10"}, parseResolvConf(strings.NewReader(resolvConf)))
+}
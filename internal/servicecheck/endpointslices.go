@@ -0,0 +1,84 @@
+package servicecheck
+
+import (
+	"context"
+	"fmt"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// endpointSliceDiscovery finds the neighbours in the EndpointSlices of the
+// kubenurse service, instead of listing the kubenurse pods.
+type endpointSliceDiscovery struct {
+	checker *Checker
+	service string
+}
+
+// EndpointSliceDiscovery returns a discovery of the ready endpoints of the
+// given service in KubenurseNamespace. The endpoints controller already
+// tracks the readiness, the node and the zone of every kubenurse pod, so
+// that neither the pods nor, with allowUnschedulable, the nodes are read.
+func (c *Checker) EndpointSliceDiscovery(service string) NeighbourDiscovery {
+	return &endpointSliceDiscovery{checker: c, service: service}
+}
+
+// Neighbours implements NeighbourDiscovery. The host IP of the neighbours is
+// not part of the EndpointSlices, so the checks through the node IP are
+// skipped.
+func (d *endpointSliceDiscovery) Neighbours(ctx context.Context) ([]*Neighbour, error) {
+	c := d.checker
+
+	esl := discoveryv1.EndpointSliceList{}
+	if err := c.client.List(ctx, &esl,
+		client.InNamespace(c.KubenurseNamespace),
+		client.MatchingLabels{discoveryv1.LabelServiceName: d.service},
+	); err != nil {
+		return nil, fmt.Errorf("list endpointslices: %w", err)
+	}
+
+	var (
+		hostname, _ = osHostname()
+		neighbours  []*Neighbour
+		seen        = make(map[string]bool) // the pods appear in a slice per address type
+	)
+
+	for i := range esl.Items {
+		for _, ep := range esl.Items[i].Endpoints {
+			if ep.TargetRef == nil || ep.TargetRef.Kind != "Pod" || ep.NodeName == nil || len(ep.Addresses) == 0 {
+				continue
+			}
+
+			if ep.TargetRef.Name == hostname { // only query other pods, not the currently running pod
+				c.discoveredNode.Store(ep.NodeName)
+				continue
+			}
+
+			// only query ready pods, which excludes the pending and terminating ones
+			if (ep.Conditions.Ready != nil && !*ep.Conditions.Ready) || seen[ep.TargetRef.Name] {
+				continue
+			}
+
+			seen[ep.TargetRef.Name] = true
+
+			if !c.allowUnschedulable && !c.nodeSchedulable(ctx, *ep.NodeName) {
+				continue
+			}
+
+			n := Neighbour{
+				PodName:  ep.TargetRef.Name,
+				PodIP:    ep.Addresses[0],
+				NodeName: *ep.NodeName,
+				NodeHash: sha256Uint64(*ep.NodeName),
+			}
+
+			if ep.Zone != nil {
+				n.Zone = *ep.Zone
+			}
+
+			neighbours = append(neighbours, &n)
+		}
+	}
+
+	return neighbours, nil
+}
@@ -0,0 +1,80 @@
+package servicecheck
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestEndpointSliceDiscovery(t *testing.T) {
+	r := require.New(t)
+
+	endpoint := func(pod, node, ip string, ready bool) discoveryv1.Endpoint {
+		return discoveryv1.Endpoint{
+			Addresses:  []string{ip},
+			Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(ready)},
+			NodeName:   ptr.To(node),
+			Zone:       ptr.To("zone-" + node),
+			TargetRef:  &v1.ObjectReference{Kind: "Pod", Name: pod},
+		}
+	}
+
+	slice := func(service, name string, addressType discoveryv1.AddressType, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+		return &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "kube-system",
+				Name:      name,
+				Labels:    map[string]string{discoveryv1.LabelServiceName: service},
+			},
+			AddressType: addressType,
+			Endpoints:   endpoints,
+		}
+	}
+
+	fakeClient := fake.NewClientBuilder().WithObjects(
+		slice("kubenurse", "kubenurse-ipv4", discoveryv1.AddressTypeIPv4,
+			endpoint("kubenurse-a", "node-a", "10.0.0.1", true),
+			endpoint("kubenurse-b", "node-b", "10.0.0.2", true),
+			endpoint("kubenurse-c", "node-c", "10.0.0.3", false),
+			endpoint("kubenurse-d", "node-d", "10.0.0.4", true),
+			discoveryv1.Endpoint{Addresses: []string{"10.0.0.5"}},
+		),
+		slice("kubenurse", "kubenurse-ipv6", discoveryv1.AddressTypeIPv6,
+			endpoint("kubenurse-b", "node-b", "fd00::2", true),
+		),
+		slice("other", "other", discoveryv1.AddressTypeIPv4, endpoint("other-e", "node-e", "10.0.0.6", true)),
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-b"}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-d"}, Spec: v1.NodeSpec{Unschedulable: true}},
+	).Build()
+
+	osHostname = func() (string, error) { return "kubenurse-a", nil }
+	defer func() { osHostname = os.Hostname }()
+
+	checker := Checker{client: fakeClient, KubenurseNamespace: "kube-system"}
+	d := checker.EndpointSliceDiscovery("kubenurse")
+
+	neighbours, err := d.Neighbours(context.Background())
+	r.NoError(err)
+	r.Equal("node-a", checker.currentNode())
+	r.Equal([]*Neighbour{{
+		PodName:  "kubenurse-b",
+		PodIP:    "10.0.0.2",
+		NodeName: "node-b",
+		NodeHash: sha256Uint64("node-b"),
+		Zone:     "zone-node-b",
+	}}, neighbours)
+
+	checker.allowUnschedulable = true
+
+	neighbours, err = d.Neighbours(context.Background())
+	r.NoError(err)
+	r.Len(neighbours, 2)
+	r.Equal("node-d", neighbours[1].NodeName)
+}
@@ -0,0 +1,243 @@
+package servicecheck
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/postfinance/kubenurse/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// clusterChecksReportInterval limits how often each kubenurse updates the
+// status of the KubenurseCheck resources, to spare the API server on large clusters.
+const clusterChecksReportInterval = time.Minute
+
+type extraCheckState struct {
+	lastRun    time.Time
+	lastResult string
+	passed     int64
+	failed     int64
+}
+
+// extraChecks returns the static extra checks together with the checks
+// defined with KubenurseCheck resources which select the current node.
+func (c *Checker) extraChecks(ctx context.Context) map[string]ExtraCheck {
+	checks := make(map[string]ExtraCheck, len(c.ExtraChecks))
+
+	for name, ec := range c.ExtraChecks {
+		checks[name] = ec
+	}
+
+	if c.ClusterChecks {
+		for name, ec := range c.clusterChecks(ctx) {
+			checks[name] = ec
+		}
+	}
+
+	// forget the state of removed checks
+	c.extraChecksStateMu.Lock()
+	if c.extraChecksState == nil {
+		c.extraChecksState = make(map[string]*extraCheckState, len(checks))
+	}
+
+	for name := range c.extraChecksState {
+		if _, ok := checks[name]; !ok {
+			delete(c.extraChecksState, name)
+		}
+	}
+	c.extraChecksStateMu.Unlock()
+
+	return checks
+}
+
+// clusterChecks lists the KubenurseCheck resources and returns the ones
+// selecting the current node, indexed by <namespace>/<name>.
+func (c *Checker) clusterChecks(ctx context.Context) map[string]ExtraCheck {
+	kcs := v1alpha1.KubenurseCheckList{}
+	if err := c.client.List(ctx, &kcs); err != nil {
+		slog.Error("cannot list KubenurseCheck resources", "err", err)
+		return nil
+	}
+
+	var nodeLabels labels.Set
+
+	checks := make(map[string]ExtraCheck, len(kcs.Items))
+
+	for i := range kcs.Items {
+		kc := &kcs.Items[i]
+
+		if len(kc.Spec.NodeSelector) > 0 {
+			if nodeLabels == nil {
+				nodeLabels = c.currentNodeLabels(ctx)
+			}
+
+			if !labels.SelectorFromSet(kc.Spec.NodeSelector).Matches(nodeLabels) {
+				continue
+			}
+		}
+
+		ec := ExtraCheck{
+			URL:            kc.Spec.URL,
+			Method:         kc.Spec.Method,
+			ExpectedStatus: kc.Spec.ExpectedStatus,
+			NodeSelector:   kc.Spec.NodeSelector,
+			resource:       client.ObjectKeyFromObject(kc),
+		}
+
+		if kc.Spec.Interval != nil {
+			ec.Interval = kc.Spec.Interval.Duration
+		}
+
+		if kc.Spec.Timeout != nil {
+			ec.Timeout = kc.Spec.Timeout.Duration
+		}
+
+		checks[ec.resource.String()] = ec
+	}
+
+	return checks
+}
+
+// currentNodeLabels returns the labels of the node the kubenurse runs on, or
+// an empty set if it cannot be determined.
+func (c *Checker) currentNodeLabels(ctx context.Context) labels.Set {
+	if c.currentNode() == "" { // the current node is found during the neighbours discovery
+		if _, err := c.Neighbours(ctx); err != nil {
+			slog.Error("cannot determine the current node", "err", err)
+		}
+	}
+
+	node := v1.Node{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: c.currentNode()}, &node); err != nil {
+		slog.Error("cannot get the current node", "node", c.currentNode(), "err", err)
+		return labels.Set{}
+	}
+
+	return node.Labels
+}
+
+// extraCheckDue reports whether the interval of the check has elapsed since its last run.
+func (c *Checker) extraCheckDue(name string, ec ExtraCheck) bool {
+	c.extraChecksStateMu.Lock()
+	defer c.extraChecksStateMu.Unlock()
+
+	st, ok := c.extraChecksState[name]
+	if !ok {
+		st = &extraCheckState{}
+		c.extraChecksState[name] = st
+	}
+
+	if ec.Interval > 0 && time.Since(st.lastRun) < ec.Interval {
+		return false
+	}
+
+	st.lastRun = time.Now()
+
+	return true
+}
+
+// runExtraCheck performs the check and records its result.
+func (c *Checker) runExtraCheck(ctx context.Context, name string, ec ExtraCheck) string {
+	if ec.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, ec.Timeout)
+		defer cancel()
+	}
+
+	var res string
+
+	if addr, useTLS, ok := tcpTarget(ec.URL); ok {
+		res = c.doTCPCheck(ctx, addr, useTLS)
+	} else {
+		method := ec.Method
+		if method == "" {
+			method = http.MethodGet
+		}
+
+		expectedStatus := ec.ExpectedStatus
+		if expectedStatus == 0 {
+			expectedStatus = http.StatusOK
+		}
+
+		res = c.doCheckRequest(ctx, method, ec.URL, expectedStatus, false)
+	}
+
+	c.extraChecksStateMu.Lock()
+	defer c.extraChecksStateMu.Unlock()
+
+	if st, ok := c.extraChecksState[name]; ok {
+		st.lastResult = res
+
+		if res == okStr {
+			st.passed++
+		} else {
+			st.failed++
+		}
+	}
+
+	return res
+}
+
+// reportClusterChecks updates the status of the KubenurseCheck resources
+// with the aggregated results of the current node.
+func (c *Checker) reportClusterChecks(ctx context.Context, checks map[string]ExtraCheck) {
+	currentNode := c.currentNode()
+	if currentNode == "" || time.Since(c.lastClusterChecksReport) < clusterChecksReportInterval {
+		return
+	}
+
+	c.lastClusterChecksReport = time.Now()
+
+	for name, ec := range checks {
+		if ec.resource.Name == "" {
+			continue
+		}
+
+		c.extraChecksStateMu.Lock()
+		st, ok := c.extraChecksState[name]
+
+		var nodeStatus v1alpha1.NodeCheckStatus
+		if ok {
+			nodeStatus = v1alpha1.NodeCheckStatus{
+				Passed:        st.passed,
+				Failed:        st.failed,
+				LastResult:    st.lastResult,
+				LastCheckTime: metav1.NewTime(st.lastRun),
+			}
+		}
+		c.extraChecksStateMu.Unlock()
+
+		if nodeStatus.LastCheckTime.IsZero() {
+			continue
+		}
+
+		// a merge patch only updates the entry of the current node, the other
+		// kubenurses update theirs concurrently
+		patch, err := json.Marshal(map[string]any{
+			"status": v1alpha1.KubenurseCheckStatus{
+				Nodes: map[string]v1alpha1.NodeCheckStatus{currentNode: nodeStatus},
+			},
+		})
+		if err != nil {
+			slog.Error("cannot marshal KubenurseCheck status", "check", name, "err", err)
+			continue
+		}
+
+		kc := v1alpha1.KubenurseCheck{ObjectMeta: metav1.ObjectMeta{
+			Namespace: ec.resource.Namespace,
+			Name:      ec.resource.Name,
+		}}
+
+		if err := c.client.Status().Patch(ctx, &kc, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			slog.Error("cannot update KubenurseCheck status", "check", name, "err", err)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package servicecheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/postfinance/kubenurse/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestClusterChecks(t *testing.T) {
+	r := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	scheme := runtime.NewScheme()
+	r.NoError(clientgoscheme.AddToScheme(scheme))
+	r.NoError(v1alpha1.AddToScheme(scheme))
+
+	node := v1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "dummy",
+		Labels: map[string]string{"zone": "a"},
+	}}
+
+	newCheck := func(name string, spec v1alpha1.KubenurseCheckSpec) *v1alpha1.KubenurseCheck {
+		return &v1alpha1.KubenurseCheck{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name},
+			Spec:       spec,
+		}
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&v1alpha1.KubenurseCheck{}).
+		WithObjects(
+			&fakeNeighbourPod, &node,
+			newCheck("get", v1alpha1.KubenurseCheckSpec{URL: server.URL}),
+			newCheck("post", v1alpha1.KubenurseCheckSpec{
+				URL:            server.URL,
+				Method:         http.MethodPost,
+				ExpectedStatus: http.StatusNoContent,
+				Interval:       &metav1.Duration{Duration: time.Hour},
+				NodeSelector:   map[string]string{"zone": "a"},
+			}),
+			newCheck("other-zone", v1alpha1.KubenurseCheckSpec{
+				URL:          server.URL,
+				NodeSelector: map[string]string{"zone": "b"},
+			}),
+		).Build()
+
+	// pretend to be the kubenurse running on the dummy node
+	osHostname = func() (string, error) { return fakeNeighbourPod.Name, nil }
+	defer func() { osHostname = os.Hostname }()
+
+	checker, err := New(fakeClient, true, 3*time.Second, func(s string) Histogram {
+		return metrics.GetOrCreatePrometheusHistogram(s)
+	})
+	r.NoError(err)
+
+	checker.SkipCheckAPIServerDNS = true
+	checker.SkipCheckAPIServerDirect = true
+	checker.SkipCheckMeIngress = true
+	checker.SkipCheckMeService = true
+	checker.SkipCheckNeighbourhood = true
+	checker.ClusterChecks = true
+
+	checker.Run(context.Background())
+
+	r.Equal(StatusOK, checker.LastResults().Checks["team-a/get"].Status)
+	r.Equal(StatusOK, checker.LastResults().Checks["team-a/post"].Status)
+	r.NotContains(checker.LastResults().Checks, "team-a/other-zone")
+
+	kc := v1alpha1.KubenurseCheck{}
+	r.NoError(fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "team-a", Name: "post"}, &kc))
+	r.Equal(int64(1), kc.Status.Nodes["dummy"].Passed)
+	r.Equal(okStr, kc.Status.Nodes["dummy"].LastResult)
+
+	t.Run("interval is respected", func(t *testing.T) {
+		r := require.New(t)
+
+		checker.lastClusterChecksReport = time.Time{}
+		checker.Run(context.Background())
+
+		r.Equal(StatusOK, checker.LastResults().Checks["team-a/post"].Status, "the previous result is kept")
+
+		r.NoError(fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "team-a", Name: "get"}, &kc))
+		r.Equal(int64(2), kc.Status.Nodes["dummy"].Passed)
+
+		r.NoError(fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "team-a", Name: "post"}, &kc))
+		r.Equal(int64(1), kc.Status.Nodes["dummy"].Passed)
+	})
+}
+
+func TestClusterChecksNodeName(t *testing.T) {
+	r := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	scheme := runtime.NewScheme()
+	r.NoError(clientgoscheme.AddToScheme(scheme))
+	r.NoError(v1alpha1.AddToScheme(scheme))
+
+	// neither a kubenurse pod nor an EndpointSlice can be discovered, the
+	// node is only known from the configuration
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&v1alpha1.KubenurseCheck{}).
+		WithObjects(
+			&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a", Labels: map[string]string{"zone": "a"}}},
+			&v1alpha1.KubenurseCheck{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "zone-a"},
+				Spec:       v1alpha1.KubenurseCheckSpec{URL: server.URL, NodeSelector: map[string]string{"zone": "a"}},
+			},
+		).Build()
+
+	checker, err := New(fakeClient, true, 3*time.Second, func(s string) Histogram {
+		return metrics.GetOrCreatePrometheusHistogram(s)
+	})
+	r.NoError(err)
+
+	checker.NodeName = "node-a"
+	checker.Discovery = checker.EndpointSliceDiscovery("kubenurse")
+	checker.SkipCheckAPIServerDNS = true
+	checker.SkipCheckAPIServerDirect = true
+	checker.SkipCheckMeIngress = true
+	checker.SkipCheckMeService = true
+	checker.SkipCheckNeighbourhood = true
+	checker.ClusterChecks = true
+
+	checker.Run(context.Background())
+
+	r.Equal(StatusOK, checker.LastResults().Checks["team-a/zone-a"].Status, "the node selector must match")
+
+	kc := v1alpha1.KubenurseCheck{}
+	r.NoError(fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "team-a", Name: "zone-a"}, &kc))
+	r.Equal(int64(1), kc.Status.Nodes["node-a"].Passed)
+}
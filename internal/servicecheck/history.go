@@ -0,0 +1,170 @@
+package servicecheck
+
+import (
+	"cmp"
+	"path"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HistoryEntry is a past result of a check.
+type HistoryEntry struct {
+	Name string `json:"name"`
+	Result
+}
+
+// HistoryFilter selects history entries, the zero value selects everything.
+type HistoryFilter struct {
+	// Checks are glob patterns, e.g. path_*, matched against the check names
+	Checks []string
+	Status Status
+	// Since and Until bound the timestamp of the results, if not zero
+	Since time.Time
+	Until time.Time
+}
+
+func (f *HistoryFilter) match(e *HistoryEntry) bool {
+	if f.Status != "" && e.Status != f.Status {
+		return false
+	}
+
+	if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+		return false
+	}
+
+	if !f.Until.IsZero() && e.Timestamp.After(f.Until) {
+		return false
+	}
+
+	if len(f.Checks) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(f.Checks, func(pattern string) bool {
+		ok, _ := path.Match(pattern, e.Name)
+		return ok
+	})
+}
+
+// resultRing holds the last results of a check, oldest first from start.
+type resultRing struct {
+	results []Result
+	start   int
+	// lastRun is the run which added the last result
+	lastRun uint64
+}
+
+func (r *resultRing) add(res Result, depth int) {
+	if len(r.results) < depth {
+		r.results = append(r.results, res)
+		return
+	}
+
+	r.results[r.start] = res
+	r.start = (r.start + 1) % len(r.results)
+}
+
+// newest returns the last added result, if any.
+func (r *resultRing) newest() (Result, bool) {
+	if len(r.results) == 0 {
+		return Result{}, false
+	}
+
+	return r.results[(r.start+len(r.results)-1)%len(r.results)], true
+}
+
+// ordered returns the results, oldest first, keeping at most depth of them.
+func (r *resultRing) ordered(depth int) []Result {
+	ordered := append(slices.Clone(r.results[r.start:]), r.results[:r.start]...)
+	return ordered[max(len(ordered)-depth, 0):]
+}
+
+// history keeps the last results of every check in a ring buffer per check.
+type history struct {
+	mu    sync.Mutex
+	depth int
+	runs  uint64
+	rings map[string]*resultRing
+}
+
+// add records the results of a run. The checks which weren't part of the
+// last depth runs, e.g. the paths to the nodes which left, are forgotten.
+func (h *history) add(snapshot *Snapshot, depth int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if depth <= 0 {
+		h.rings, h.depth = nil, depth
+		return
+	}
+
+	if h.rings == nil {
+		h.rings = make(map[string]*resultRing, len(snapshot.Checks))
+	}
+
+	if depth != h.depth { // the depth was reconfigured
+		for _, ring := range h.rings {
+			ring.results, ring.start = ring.ordered(depth), 0
+		}
+
+		h.depth = depth
+	}
+
+	h.runs++
+
+	for name, res := range snapshot.Checks {
+		ring, ok := h.rings[name]
+		if !ok {
+			ring = &resultRing{}
+			h.rings[name] = ring
+		}
+
+		// an extra check which wasn't due reports the result of its last
+		// run again, it must only be recorded once
+		if newest, ok := ring.newest(); !ok || !newest.Timestamp.Equal(res.Timestamp) {
+			ring.add(res, depth)
+		}
+
+		ring.lastRun = h.runs
+	}
+
+	for name, ring := range h.rings {
+		if h.runs-ring.lastRun >= uint64(depth) {
+			delete(h.rings, name)
+		}
+	}
+}
+
+// entries returns the entries selected by the filter, sorted by timestamp.
+func (h *history) entries(filter *HistoryFilter) []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries := []HistoryEntry{}
+
+	for name, ring := range h.rings {
+		for _, res := range ring.ordered(h.depth) {
+			e := HistoryEntry{Name: name, Result: res}
+			if filter.match(&e) {
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	slices.SortStableFunc(entries, func(a, b HistoryEntry) int {
+		if c := a.Timestamp.Compare(b.Timestamp); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return entries
+}
+
+// History returns the past results of the checks selected by the filter,
+// sorted by timestamp. At most HistoryDepth results are kept per check.
+func (c *Checker) History(filter HistoryFilter) []HistoryEntry {
+	return c.history.entries(&filter)
+}
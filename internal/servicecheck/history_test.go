@@ -0,0 +1,86 @@
+package servicecheck
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	r := require.New(t)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := history{}
+
+	run := func(i int, depth int, checks map[string]Status) {
+		snapshot := Snapshot{Checks: make(map[string]Result, len(checks))}
+		for name, status := range checks {
+			snapshot.Checks[name] = Result{Status: status, Timestamp: start.Add(time.Duration(i) * time.Minute)}
+		}
+
+		h.add(&snapshot, depth)
+	}
+
+	names := func(entries []HistoryEntry) []string {
+		out := make([]string, 0, len(entries))
+		for i := range entries {
+			out = append(out, entries[i].Name+"@"+entries[i].Timestamp.Format("04"))
+		}
+
+		return out
+	}
+
+	for i := range 5 {
+		status := StatusOK
+		if i%2 == 1 {
+			status = StatusError
+		}
+
+		run(i, 3, map[string]Status{"api_server_direct": StatusOK, "path_node-b": status})
+	}
+
+	// only the last 3 results are kept, oldest first
+	r.Equal([]string{
+		"api_server_direct@02", "path_node-b@02",
+		"api_server_direct@03", "path_node-b@03",
+		"api_server_direct@04", "path_node-b@04",
+	}, names(h.entries(&HistoryFilter{})))
+
+	r.Equal([]string{"path_node-b@03"}, names(h.entries(&HistoryFilter{Checks: []string{"path_*"}, Status: StatusError})))
+	r.Equal([]string{"api_server_direct@03", "api_server_direct@04"}, names(h.entries(&HistoryFilter{
+		Checks: []string{"api_*"},
+		Since:  start.Add(3 * time.Minute),
+	})))
+	r.Equal([]string{"path_node-b@02"}, names(h.entries(&HistoryFilter{
+		Checks: []string{"path_node-b"},
+		Until:  start.Add(2 * time.Minute),
+	})))
+
+	// the path to a node which left is forgotten after depth runs
+	run(5, 3, map[string]Status{"api_server_direct": StatusOK})
+	run(6, 3, map[string]Status{"api_server_direct": StatusOK})
+	r.Len(h.entries(&HistoryFilter{Checks: []string{"path_*"}}), 3)
+
+	run(7, 3, map[string]Status{"api_server_direct": StatusOK})
+	r.Empty(h.entries(&HistoryFilter{Checks: []string{"path_*"}}))
+
+	// reducing the depth keeps the most recent results
+	run(8, 2, map[string]Status{"api_server_direct": StatusOK})
+	r.Equal([]string{"api_server_direct@07", "api_server_direct@08"}, names(h.entries(&HistoryFilter{})))
+
+	// increasing it lets the history grow again
+	run(9, 4, map[string]Status{"api_server_direct": StatusOK})
+	run(10, 4, map[string]Status{"api_server_direct": StatusOK})
+	r.Len(h.entries(&HistoryFilter{}), 4)
+
+	// the result of an extra check which wasn't due is recorded once
+	extra := Result{Status: StatusOK, Timestamp: start.Add(10 * time.Minute)}
+	h.add(&Snapshot{Checks: map[string]Result{"my_extra": extra}}, 4)
+	h.add(&Snapshot{Checks: map[string]Result{"my_extra": extra}}, 4)
+	r.Equal([]string{"my_extra@10"}, names(h.entries(&HistoryFilter{Checks: []string{"my_extra"}})))
+
+	// a depth of zero disables the history
+	run(11, 0, map[string]Status{"api_server_direct": StatusOK})
+	r.Empty(h.entries(&HistoryFilter{}))
+}
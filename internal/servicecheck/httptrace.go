@@ -11,12 +11,19 @@ import (
 
 	"github.com/VictoriaMetrics/metrics"
 	"github.com/postfinance/kubenurse/internal/util"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
 )
 
 // unique type for context.Context to avoid collisions.
 type (
 	kubenurseTypeKey           struct{}
 	kubenurseErrorAccountedKey struct{}
+	kubenurseExpectedStatusKey struct{}
+	kubenurseResultKey         struct{}
+	kubenurseLabelsKey         struct{}
+	kubenurseSeriesKey         struct{}
+	kubenurseTraceContextKey   struct{}
 )
 
 const (
@@ -27,6 +34,7 @@ const (
 )
 
 type Histogram interface {
+	Update(v float64)
 	UpdateDuration(start time.Time)
 }
 
@@ -40,21 +48,27 @@ func (rt RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
 // withHttptrace collects traces, measures durations and counts requests+errors.
 func withHttptrace(next http.RoundTripper, histogramGetter func(string) Histogram) http.RoundTripper {
 	collectMetric := func(traceEventType string, start time.Time, r *http.Request, err error) {
+		if err != nil {
+			recorderFrom(r.Context()).fail(traceEventType)
+		} else {
+			recorderFrom(r.Context()).phase(traceEventType, time.Since(start))
+		}
+
 		go func() { // we run the following in a separate goroutine, because the ClientTrace functions are called in a blocking manner
 			kubenurseTypeLabel := r.Context().Value(kubenurseTypeKey{}).(string)
 			errorAccounted := r.Context().Value(kubenurseErrorAccountedKey{}).(*atomic.Bool)
-			l := []string{"type", kubenurseTypeLabel, "event", traceEventType}
+			l := append([]string{"type", kubenurseTypeLabel, "event", traceEventType}, metricLabels(r.Context())...)
 
 			// If we get an error inside a trace, log it
 			if err != nil {
-				metrics.GetOrCreateCounter(util.GenMetricsName(errCounter, l...)).Inc()
+				metrics.GetOrCreateCounter(trackSeries(r.Context(), util.GenMetricsName(errCounter, l...))).Inc()
 				errorAccounted.Store(true) // mark the error as accounted, so we don't increase the error counter twice.
 				slog.Error("request failure in httptrace", "event_type", traceEventType, "request_type", kubenurseTypeLabel, "err", err)
 
 				return
 			}
 
-			histogramGetter(util.GenMetricsName(hcTraceReqDurSec, l...)).UpdateDuration(start)
+			histogramGetter(trackSeries(r.Context(), util.GenMetricsName(hcTraceReqDurSec, l...))).UpdateDuration(start)
 		}()
 	}
 
@@ -63,6 +77,12 @@ func withHttptrace(next http.RoundTripper, histogramGetter func(string) Histogra
 		// Capture request time
 		start := time.Now()
 
+		// The phases are recorded as child spans of the request span
+		ctx, span := startRequestSpan(r)
+		defer span.End()
+
+		phases := newPhaseSpans(ctx)
+
 		// Add tracing hooks
 		trace := &httptrace.ClientTrace{
 			GotConn: func(_ httptrace.GotConnInfo) {
@@ -70,63 +90,89 @@ func withHttptrace(next http.RoundTripper, histogramGetter func(string) Histogra
 			},
 			DNSStart: func(_ httptrace.DNSStartInfo) {
 				collectMetric("dns_start", start, r, nil)
+				phases.start("dns")
 			},
 			DNSDone: func(info httptrace.DNSDoneInfo) {
 				collectMetric("dns_done", start, r, info.Err)
+				phases.end("dns", info.Err)
 			},
 			ConnectStart: func(_, _ string) {
 				collectMetric("connect_start", start, r, nil)
+				phases.start("connect")
 			},
 			ConnectDone: func(_, _ string, err error) {
 				collectMetric("connect_done", start, r, err)
+				phases.end("connect", err)
 			},
 			TLSHandshakeStart: func() {
 				collectMetric("tls_handshake_start", start, r, nil)
+				phases.start("tls_handshake")
 			},
 			TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
 				collectMetric("tls_handshake_done", start, r, err)
+				phases.end("tls_handshake", err)
 			},
 			WroteRequest: func(info httptrace.WroteRequestInfo) {
 				collectMetric("wrote_request", start, r, info.Err)
+				phases.start("wait_first_byte")
 			},
 			GotFirstResponseByte: func() {
 				collectMetric("got_first_resp_byte", start, r, nil)
+				phases.end("wait_first_byte", nil)
 			},
 		}
 
-		// Do request with tracing enabled
-		r = r.WithContext(httptrace.WithClientTrace(r.Context(), trace))
+		// Do request with tracing enabled, and propagate the trace context to
+		// the kubenurses
+		r = withTraceContext(httptrace.WithClientTrace(ctx, trace), r)
 
 		rt := next // variable pinning :) essential, to prevent always re-instrumenting the original variable
 
 		kubenurseRequestType := r.Context().Value(kubenurseTypeKey{}).(string)
 		errorAccounted := r.Context().Value(kubenurseErrorAccountedKey{}).(*atomic.Bool)
+		rec := recorderFrom(r.Context())
+
+		rec.startRequest(r.URL.Redacted())
 
 		start = time.Now()
 		resp, err := rt.RoundTrip(r)
-		l := []string{"type", kubenurseRequestType}
+		l := append([]string{"type", kubenurseRequestType}, metricLabels(r.Context())...)
 
 		if err == nil {
-			metrics.GetOrCreateCounter(util.GenMetricsName(
-				hcReqTotal, append(l, "code", fmt.Sprintf("%d", resp.StatusCode))...),
+			rec.setHTTPCode(resp.StatusCode)
+			span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+			metrics.GetOrCreateCounter(trackSeries(r.Context(), util.GenMetricsName(
+				hcReqTotal, append(l, "code", fmt.Sprintf("%d", resp.StatusCode))...)),
 			).Inc()
 
-			histogramGetter(util.GenMetricsName(hcReqDurSec, l...)).UpdateDuration(start)
+			histogramGetter(trackSeries(r.Context(), util.GenMetricsName(hcReqDurSec, l...))).UpdateDuration(start)
+
+			expectedStatus, ok := r.Context().Value(kubenurseExpectedStatusKey{}).(int)
+			if !ok {
+				expectedStatus = http.StatusOK
+			}
 
-			if resp.StatusCode != http.StatusOK {
+			if resp.StatusCode != expectedStatus {
 				eventType := fmt.Sprintf("status_code_%d", resp.StatusCode)
+				rec.fail(eventType)
+				span.SetStatus(codes.Error, eventType)
 
-				metrics.GetOrCreateCounter(util.GenMetricsName(errCounter, append(l, "event", eventType)...)).Inc()
+				metrics.GetOrCreateCounter(trackSeries(r.Context(),
+					util.GenMetricsName(errCounter, append(l, "event", eventType)...))).Inc()
 				slog.Error("request failure in httptrace",
 					"event_type", eventType,
 					"request_type", kubenurseRequestType)
 			}
 		} else {
 			eventType := "round_trip_error"
-			metrics.GetOrCreateCounter(util.GenMetricsName(hcReqTotal, append(l, "code", eventType)...)).Inc()
+			rec.fail(eventType)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, eventType)
+			metrics.GetOrCreateCounter(trackSeries(r.Context(), util.GenMetricsName(hcReqTotal, append(l, "code", eventType)...))).Inc()
 
 			if !errorAccounted.Load() {
-				metrics.GetOrCreateCounter(util.GenMetricsName(errCounter, append(l, "event", eventType)...)).Inc()
+				metrics.GetOrCreateCounter(trackSeries(r.Context(),
+					util.GenMetricsName(errCounter, append(l, "event", eventType)...))).Inc()
 			}
 			slog.Error("request failure in httptrace",
 				"event_type", eventType,
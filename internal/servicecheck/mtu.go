@@ -0,0 +1,109 @@
+package servicecheck
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/postfinance/kubenurse/internal/util"
+)
+
+const (
+	// MaxPayloadSize is the largest body accepted and returned by /alwayshappy.
+	MaxPayloadSize = 64 * 1024
+
+	neighbourMTUMaxSize  = "neighbour_mtu_max_size_bytes"
+	neighbourMTUFailures = "neighbour_mtu_failures_total"
+)
+
+// doMTUCheck sends a request with a body of every size in MTUSizes to the
+// /alwayshappy endpoint of a neighbour, which answers with a body of the same
+// size, so that large packets are sent in both directions. The largest size
+// which got through is exposed as a gauge, and the failures of every size are
+// counted, until the neighbour isn't checked anymore. The check fails if any
+// size didn't get through.
+func (c *Checker) doMTUCheck(ctx context.Context, url, dstNode string) string {
+	l := []string{"src_node", c.currentNode(), "dst_node", dstNode}
+	largest := 0
+
+	var failed []string
+
+	for _, size := range slices.Sorted(slices.Values(c.MTUSizes)) {
+		if err := c.doPayloadRequest(ctx, url, size); err != nil {
+			c.pairCounter(dstNode, neighbourMTUFailures, append(l, "size", strconv.Itoa(size))...).Inc()
+
+			failed = append(failed, fmt.Sprintf("%d bytes: %s", size, err))
+
+			continue
+		}
+
+		largest = size
+	}
+
+	c.pairGauge(dstNode, neighbourMTUMaxSize, l...).Set(float64(largest))
+
+	if len(failed) > 0 {
+		return strings.Join(failed, ", ")
+	}
+
+	return okStr
+}
+
+// doPayloadRequest POSTs size bytes to url and expects size bytes in return.
+func (c *Checker) doPayloadRequest(ctx context.Context, url string, size int) error {
+	// the errors of every size must be accounted separately
+	ctx = context.WithValue(ctx, kubenurseErrorAccountedKey{}, &atomic.Bool{})
+	ctx = context.WithValue(ctx, kubenurseExpectedStatusKey{}, http.StatusOK)
+
+	if c.MTUTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, c.MTUTimeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url+"?size="+strconv.Itoa(size),
+		bytes.NewReader(make([]byte, size)))
+	if err != nil {
+		return err
+	}
+
+	hostname, _ := os.Hostname()
+	req.Header.Add(NeighbourOriginHeader, hostname)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	// the response body must be read, as the large packets are in there
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		requestType, _ := ctx.Value(kubenurseTypeKey{}).(string)
+		recorderFrom(ctx).fail("read_body")
+		metrics.GetOrCreateCounter(trackSeries(ctx, util.GenMetricsName(errCounter, "type", requestType, "event", "read_body"))).Inc()
+		slog.Error("request failure in mtu check", "event_type", "read_body", "request_type", requestType, "err", err)
+
+		return fmt.Errorf("read response: %w", err)
+	}
+
+	if n != int64(size) {
+		return fmt.Errorf("received %d bytes instead of %d", n, size)
+	}
+
+	return nil
+}
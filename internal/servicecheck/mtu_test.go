@@ -0,0 +1,64 @@
+package servicecheck
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMTUCheck(t *testing.T) {
+	r := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		if size > 1500 { // large packets are dropped, the connection hangs
+			<-r.Context().Done()
+			return
+		}
+
+		_, _ = w.Write(make([]byte, size))
+	}))
+	defer server.Close()
+
+	checker, err := New(fake.NewFakeClient(), false, 3*time.Second, func(s string) Histogram {
+		return metrics.GetOrCreatePrometheusHistogram(s)
+	})
+	r.NoError(err)
+
+	checker.MTUSizes = []int{9000, 1400, 1500}
+	checker.MTUTimeout = 100 * time.Millisecond
+	checker.NodeName = "src"
+
+	ctx := context.WithValue(context.Background(), kubenurseTypeKey{}, "mtu_dst")
+
+	res := checker.doMTUCheck(ctx, server.URL+"/alwayshappy", "dst")
+	r.Contains(res, "9000 bytes")
+	r.NotContains(res, "1500 bytes")
+
+	var sb strings.Builder
+
+	metrics.WritePrometheus(&sb, false)
+	r.Contains(sb.String(), `kubenurse_neighbour_mtu_max_size_bytes{src_node="src",dst_node="dst"} 1500`)
+	r.Contains(sb.String(), `kubenurse_neighbour_mtu_failures_total{src_node="src",dst_node="dst",size="9000"} 1`)
+	r.NotContains(sb.String(), `kubenurse_neighbour_mtu_failures_total{src_node="src",dst_node="dst",size="1500"}`)
+
+	// the series of a neighbour which is deselected are removed after the
+	// first run which didn't check it
+	checker.pairSeries.sweep()
+	r.Contains(metrics.ListMetricNames(), `kubenurse_neighbour_mtu_max_size_bytes{src_node="src",dst_node="dst"}`)
+
+	checker.pairSeries.sweep()
+	r.NotContains(metrics.ListMetricNames(), `kubenurse_neighbour_mtu_max_size_bytes{src_node="src",dst_node="dst"}`)
+	r.NotContains(metrics.ListMetricNames(), `kubenurse_neighbour_mtu_failures_total{src_node="src",dst_node="dst",size="9000"}`)
+}
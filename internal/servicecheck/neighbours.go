@@ -6,7 +6,10 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"sync"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -15,10 +18,7 @@ import (
 )
 
 //nolint:gochecknoglobals // used during testing
-var (
-	osHostname  = os.Hostname
-	currentNode string
-)
+var osHostname = os.Hostname
 
 const (
 	NeighbourOriginHeader = "KUBENURSE-NEIGHBOUR-ORIGIN"
@@ -31,6 +31,27 @@ type Neighbour struct {
 	HostIP   string
 	NodeName string
 	NodeHash uint64
+	// Zone and Region of the node, they are known with the EndpointSlice
+	// discovery or the zone selection
+	Zone   string `json:",omitempty"`
+	Region string `json:",omitempty"`
+	// Port of the kubenurse listener of the neighbour, zero means the default
+	// port, 8080 or 8443 with TLS
+	Port int `json:",omitempty"`
+}
+
+// url returns the url of the /alwayshappy endpoint of the neighbour.
+func (n *Neighbour) url(useTLS bool) string {
+	scheme, port := "http", 8080
+	if useTLS {
+		scheme, port = "https", 8443
+	}
+
+	if n.Port != 0 {
+		port = n.Port
+	}
+
+	return scheme + "://" + net.JoinHostPort(n.PodIP, strconv.Itoa(port)) + "/alwayshappy"
 }
 
 // getNeighbours returns a slice of neighbour kubenurses for the given namespace and labelSelector.
@@ -61,17 +82,12 @@ func (c *Checker) getNeighbours(ctx context.Context, namespace, labelSelector st
 		}
 
 		if pod.Name == hostname { // only query other pods, not the currently running pod
-			currentNode = pod.Spec.NodeName
+			c.discoveredNode.Store(&pod.Spec.NodeName)
 			continue
 		}
 
-		if !c.allowUnschedulable { // if we disallow unschedulable nodes, we have to check their status
-			node := v1.Node{}
-			if err := c.client.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, &node); err != nil || IsNodeUnschedulable(&node) {
-				// Node not found, unschedulable, or lookup errored: do not include this pod in the neighbour list.
-				// This prevents querying pods whose node was deleted before the pod disappeared from the cache.
-				continue
-			}
+		if !c.allowUnschedulable && !c.nodeSchedulable(ctx, pod.Spec.NodeName) {
+			continue
 		}
 
 		n := Neighbour{
@@ -87,6 +103,19 @@ func (c *Checker) getNeighbours(ctx context.Context, namespace, labelSelector st
 	return neighbours, nil
 }
 
+// nodeSchedulable reports whether the node exists and is schedulable. If the
+// node is not found, unschedulable, or the lookup errored, its kubenurse must
+// not be included in the neighbour list. This prevents querying pods whose
+// node was deleted before the pod disappeared from the cache.
+func (c *Checker) nodeSchedulable(ctx context.Context, nodeName string) bool {
+	node := v1.Node{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: nodeName}, &node); err != nil {
+		return false
+	}
+
+	return !IsNodeUnschedulable(&node)
+}
+
 // IsNodeUnschedulable reports whether a node must be considered unschedulable.
 // It checks the deprecated Spec.Unschedulable field and the canonical taint
 // node.kubernetes.io/unschedulable:NoSchedule used by kubectl cordon/drain.
@@ -104,12 +133,26 @@ func IsNodeUnschedulable(n *v1.Node) bool {
 	return false
 }
 
+// currentNode returns the node the kubenurse runs on, which is either
+// configured or found during the neighbours discovery, or an empty string.
+func (c *Checker) currentNode() string {
+	if c.NodeName != "" {
+		return c.NodeName
+	}
+
+	if node := c.discoveredNode.Load(); node != nil {
+		return *node
+	}
+
+	return ""
+}
+
 func (c *Checker) filterNeighbours(nh []*Neighbour) []*Neighbour {
 	m := make(map[uint64]*Neighbour, c.NeighbourLimit+1)
 
 	sl := make(Uint64Heap, 0, c.NeighbourLimit+1)
 	h := &sl
-	currentNodeHash := sha256Uint64(currentNode)
+	currentNodeHash := sha256Uint64(c.currentNode())
 
 	heap.Init(h)
 
@@ -156,3 +199,23 @@ func (h *Uint64Heap) Pop() any {
 
 	return x
 }
+
+// runHostPathChecks checks the /alwayshappy endpoint of every neighbour
+// through its node IP, on the host listener. Comparing them with the path
+// checks separates pod network failures from node network failures.
+func (c *Checker) runHostPathChecks(ctx context.Context, wg *sync.WaitGroup, res *sync.Map, neighbours []*Neighbour) {
+	for _, neighbour := range neighbours {
+		if neighbour.HostIP == "" { // not yet known for a pending pod
+			continue
+		}
+
+		url := "http://" + net.JoinHostPort(neighbour.HostIP, strconv.Itoa(c.HostPathPort)) + "/alwayshappy"
+		check := func(ctx context.Context) string {
+			return c.doRequest(ctx, url, true)
+		}
+
+		wg.Add(1)
+
+		go c.measure(c.withDstNode(ctx, neighbour.NodeName), wg, res, check, "host_path_"+neighbour.NodeName)
+	}
+}
@@ -1,10 +1,17 @@
 package servicecheck
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func generateNeighbours(n int) (nh []*Neighbour) {
@@ -35,7 +42,7 @@ func BenchmarkNodeFiltering(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		currentNode = nh[i%len(nh)].NodeName
+		checker.NodeName = nh[i%len(nh)].NodeName
 		b.StartTimer()
 		checker.filterNeighbours(nh)
 		b.StopTimer()
@@ -57,7 +64,7 @@ func TestNodeFiltering(t *testing.T) {
 		counter := make(map[string]int, n)
 
 		for i := range n {
-			currentNode = nh[i].NodeName
+			checker.NodeName = nh[i].NodeName
 			filtered := checker.filterNeighbours(nh)
 			require.Equal(t, neighbourLimit, len(filtered))
 
@@ -72,3 +79,32 @@ func TestNodeFiltering(t *testing.T) {
 
 	})
 }
+
+func TestHostPathCheck(t *testing.T) {
+	r := require.New(t)
+
+	// the host listener of the dummy neighbour, whose host IP is 127.0.0.1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	checker, err := New(fake.NewFakeClient(&fakeNeighbourPod), true, 3*time.Second, func(s string) Histogram {
+		return metrics.GetOrCreatePrometheusHistogram(s)
+	})
+	r.NoError(err)
+
+	checker.SkipCheckAPIServerDNS = true
+	checker.SkipCheckAPIServerDirect = true
+	checker.SkipCheckMeIngress = true
+	checker.SkipCheckMeService = true
+	checker.KubenurseNamespace = fakeNeighbourPod.Namespace
+
+	checker.CheckHostPath = true
+	checker.HostPathPort = server.Listener.Addr().(*net.TCPAddr).Port
+
+	checker.Run(context.Background())
+
+	r.Equal(StatusOK, checker.LastResults().Checks["host_path_dummy"].Status)
+	r.Contains(checker.LastResults().Checks, "path_dummy")
+}
@@ -0,0 +1,80 @@
+package servicecheck
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// runLocalNodeHealthChecks checks the /healthz endpoints of the kubelet and
+// kube-proxy of the node on which the kubenurse runs.
+func (c *Checker) runLocalNodeHealthChecks(ctx context.Context, wg *sync.WaitGroup, res *sync.Map) {
+	hostIP, err := c.localHostIP(ctx)
+	if err != nil {
+		if c.CheckKubelet {
+			res.Store(Kubelet, newResult(err.Error()))
+		}
+
+		if c.CheckKubeProxy {
+			res.Store(KubeProxy, newResult(err.Error()))
+		}
+
+		return
+	}
+
+	c.runNodeHealthChecks(ctx, wg, res, hostIP, "")
+}
+
+// runNodeHealthChecks checks the /healthz endpoints of the enabled node
+// components at hostIP. The suffix is appended to the check names.
+func (c *Checker) runNodeHealthChecks(ctx context.Context, wg *sync.WaitGroup, res *sync.Map, hostIP, suffix string) {
+	if hostIP == "" { // not yet known for a pending pod
+		return
+	}
+
+	components := []struct {
+		enabled bool
+		name    string
+		port    int
+	}{
+		{c.CheckKubelet, Kubelet, c.KubeletPort},
+		{c.CheckKubeProxy, KubeProxy, c.KubeProxyPort},
+	}
+
+	for _, comp := range components {
+		if !comp.enabled {
+			continue
+		}
+
+		url := "http://" + net.JoinHostPort(hostIP, strconv.Itoa(comp.port)) + "/healthz"
+		check := func(ctx context.Context) string {
+			return c.doRequest(ctx, url, false)
+		}
+
+		wg.Add(1)
+
+		go c.measure(ctx, wg, res, check, comp.name+suffix)
+	}
+}
+
+// localHostIP returns the IP of the node on which the kubenurse runs, from
+// the status of its own pod.
+func (c *Checker) localHostIP(ctx context.Context) (string, error) {
+	hostname, _ := osHostname()
+
+	pod := v1.Pod{}
+	if err := c.client.Get(ctx, client.ObjectKey{Namespace: c.KubenurseNamespace, Name: hostname}, &pod); err != nil {
+		return "", fmt.Errorf("get own pod: %w", err)
+	}
+
+	if pod.Status.HostIP == "" {
+		return "", fmt.Errorf("pod %s has no host IP yet", hostname)
+	}
+
+	return pod.Status.HostIP, nil
+}
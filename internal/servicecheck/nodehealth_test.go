@@ -0,0 +1,77 @@
+package servicecheck
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNodeHealthChecks(t *testing.T) {
+	r := require.New(t)
+
+	newHealthz := func(status int) int {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/healthz" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.WriteHeader(status)
+		}))
+		t.Cleanup(server.Close)
+
+		return server.Listener.Addr().(*net.TCPAddr).Port
+	}
+
+	self := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubenurse-self", Namespace: "kube-system"},
+		Spec:       v1.PodSpec{NodeName: "self"},
+		Status:     v1.PodStatus{HostIP: "127.0.0.1", PodIP: "127.0.0.1", Phase: v1.PodRunning},
+	}
+
+	osHostname = func() (string, error) { return self.Name, nil }
+	defer func() { osHostname = os.Hostname }()
+
+	checker, err := New(fake.NewFakeClient(&self, &fakeNeighbourPod), true, 3*time.Second, func(s string) Histogram {
+		return metrics.GetOrCreatePrometheusHistogram(s)
+	})
+	r.NoError(err)
+
+	checker.SkipCheckAPIServerDNS = true
+	checker.SkipCheckAPIServerDirect = true
+	checker.SkipCheckMeIngress = true
+	checker.SkipCheckMeService = true
+	checker.KubenurseNamespace = "kube-system"
+
+	checker.CheckKubelet = true
+	checker.KubeletPort = newHealthz(http.StatusOK)
+	checker.CheckKubeProxy = true
+	checker.KubeProxyPort = newHealthz(http.StatusServiceUnavailable)
+	checker.CheckNeighbourNodeHealth = true
+
+	checker.Run(context.Background())
+
+	r.Equal(StatusOK, checker.LastResults().Checks[Kubelet].Status)
+	r.Equal("503 Service Unavailable", checker.LastResults().Checks[KubeProxy].Message)
+	r.Equal(StatusOK, checker.LastResults().Checks["kubelet_dummy"].Status)
+	r.Equal("503 Service Unavailable", checker.LastResults().Checks["kube_proxy_dummy"].Message)
+
+	t.Run("own pod not found", func(t *testing.T) {
+		osHostname = func() (string, error) { return "unknown", nil }
+
+		checker.CheckNeighbourNodeHealth = false
+		checker.Run(context.Background())
+
+		require.Contains(t, checker.LastResults().Checks[Kubelet].Message, "get own pod")
+	})
+}
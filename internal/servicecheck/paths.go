@@ -0,0 +1,54 @@
+package servicecheck
+
+import (
+	"strings"
+)
+
+// PathResult is the last result of the path check of a neighbour.
+type PathResult struct {
+	Status         string  `json:"status"`
+	LatencySeconds float64 `json:"latency_seconds"`
+}
+
+// PathResults returns the node on which the kubenurse runs, and the last
+// results of its path checks by destination node. The status is ok or the
+// error message. Both come from the last run, so it is safe to call
+// concurrently with Run and a reconfiguration of the checker.
+func (c *Checker) PathResults() (string, map[string]PathResult) {
+	var (
+		last = c.LastResults()
+		node string
+	)
+
+	if last != nil {
+		node = last.Node
+	}
+
+	return node, last.PathResults()
+}
+
+// PathResults returns the results of the path checks of the snapshot by
+// destination node, s may be nil.
+func (s *Snapshot) PathResults() map[string]PathResult {
+	results := make(map[string]PathResult)
+
+	if s == nil {
+		return results
+	}
+
+	for name, res := range s.Checks {
+		node, ok := strings.CutPrefix(name, "path_")
+		if !ok {
+			continue
+		}
+
+		pr := PathResult{Status: string(res.Status), LatencySeconds: res.LatencySeconds}
+		if res.Status == StatusError {
+			pr.Status = res.Message
+		}
+
+		results[node] = pr
+	}
+
+	return results
+}
@@ -0,0 +1,178 @@
+package servicecheck
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	StatusOK      Status = okStr
+	StatusError   Status = errStr
+	StatusSkipped Status = skippedStr
+)
+
+// Result is the result of a single check.
+type Result struct {
+	Status Status `json:"status"`
+	// Message describes the failure, it is empty if the check succeeded
+	Message string `json:"message,omitempty"`
+	// ErrorClass is the event of the first error of the check, as in the
+	// event label of kubenurse_errors_total, e.g. status_code_503,
+	// connect_done or round_trip_error
+	ErrorClass string `json:"error_class,omitempty"`
+	// HTTPCode is the status code of the last response of an http check
+	HTTPCode int `json:"http_code,omitempty"`
+	// Target is the url or address of the last request of the check
+	Target         string    `json:"target,omitempty"`
+	LatencySeconds float64   `json:"latency_seconds"`
+	Timestamp      time.Time `json:"timestamp"`
+	// PhasesSeconds are the durations from the start of the last request to
+	// the httptrace events, e.g. dns_done, connect_done or got_first_resp_byte
+	PhasesSeconds map[string]float64 `json:"phases_seconds,omitempty"`
+}
+
+// Snapshot holds the results of the last run of all the checks. It is
+// replaced as a whole after every run, and must not be modified.
+type Snapshot struct {
+	Checks map[string]Result `json:"checks"`
+	// Node is the node on which the kubenurse ran, if it is known
+	Node string `json:"node,omitempty"`
+	// Neighbourhood are the neighbours discovered during the run
+	Neighbourhood []*Neighbour `json:"neighbourhood,omitempty"`
+	Timestamp     time.Time    `json:"timestamp"`
+}
+
+// LastResults returns the results of the last run, or nil before the end of
+// the first run. It is safe to call concurrently with Run.
+func (c *Checker) LastResults() *Snapshot {
+	return c.lastSnapshot.Load()
+}
+
+// check returns the result of the named check, s may be nil.
+func (s *Snapshot) check(name string) (Result, bool) {
+	if s == nil {
+		return Result{}, false
+	}
+
+	res, ok := s.Checks[name]
+
+	return res, ok
+}
+
+// newResult converts the string returned by a check, which is either ok,
+// skipped or the error message.
+func newResult(s string) Result {
+	res := Result{Timestamp: time.Now()}
+
+	switch s {
+	case okStr:
+		res.Status = StatusOK
+	case skippedStr:
+		res.Status = StatusSkipped
+	default:
+		res.Status = StatusError
+		res.Message = s
+	}
+
+	return res
+}
+
+// resultRecorder collects the details of the result of a check from the
+// requests it performs. It is passed in the context of the check, and its
+// methods can be called on nil, when a request is done outside of a check.
+type resultRecorder struct {
+	mu         sync.Mutex
+	errorClass string
+	httpCode   int
+	target     string
+	phases     map[string]time.Duration
+}
+
+func recorderFrom(ctx context.Context) *resultRecorder {
+	rec, _ := ctx.Value(kubenurseResultKey{}).(*resultRecorder)
+	return rec
+}
+
+// startRequest resets the details of the previous request of the check,
+// except for the error class.
+func (r *resultRecorder) startRequest(target string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.target = target
+	r.httpCode = 0
+	r.phases = nil
+}
+
+func (r *resultRecorder) setHTTPCode(code int) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.httpCode = code
+}
+
+func (r *resultRecorder) phase(event string, d time.Duration) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.phases == nil {
+		r.phases = make(map[string]time.Duration)
+	}
+
+	r.phases[event] = d
+}
+
+// fail records the class of an error, only the first one is kept.
+func (r *resultRecorder) fail(event string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.errorClass == "" {
+		r.errorClass = event
+	}
+}
+
+// result builds the result of the check from the string it returned.
+func (r *resultRecorder) result(s string, latency time.Duration) Result {
+	res := newResult(s)
+	res.LatencySeconds = latency.Seconds()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res.HTTPCode = r.httpCode
+	res.Target = r.target
+
+	if res.Status == StatusError {
+		res.ErrorClass = r.errorClass
+	}
+
+	if len(r.phases) > 0 {
+		res.PhasesSeconds = make(map[string]float64, len(r.phases))
+		for event, d := range r.phases {
+			res.PhasesSeconds[event] = d.Seconds()
+		}
+	}
+
+	return res
+}
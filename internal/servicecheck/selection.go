@@ -0,0 +1,200 @@
+package servicecheck
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Neighbour selection strategies, used when there are more than
+// NeighbourLimit neighbours.
+const (
+	// SelectionHash selects the NeighbourLimit closest neighbours by the
+	// distance of the hashes of their node names.
+	SelectionHash = "hash"
+	// SelectionZone selects a minimum number of neighbours in the own zone,
+	// in every other zone and in every other region, completed with the
+	// closest neighbours by hash up to NeighbourLimit.
+	SelectionZone = "zone"
+)
+
+// NeighbourTopology are the minimum numbers of neighbours selected by the
+// zone strategy.
+type NeighbourTopology struct {
+	// SameZone is the number of neighbours in the zone of the kubenurse
+	SameZone int
+	// PerZone is the number of neighbours in every other zone of the region
+	PerZone int
+	// PerRegion is the number of neighbours in every other region
+	PerRegion int
+}
+
+// selectNeighbours selects the neighbours to check with the configured
+// strategy.
+func (c *Checker) selectNeighbours(ctx context.Context, nh []*Neighbour) []*Neighbour {
+	if c.NeighbourSelection == SelectionZone {
+		c.resolveTopology(ctx, nh)
+	}
+
+	if c.NeighbourLimit == 0 || len(nh) <= c.NeighbourLimit {
+		return nh
+	}
+
+	epoch := c.rotationEpoch(time.Now())
+
+	if c.NeighbourSelection == SelectionZone {
+		return c.filterNeighboursByZone(nh, epoch)
+	}
+
+	if c.NeighbourRotation > 0 {
+		return c.orderByHashDistance(nh, epoch)[:c.NeighbourLimit]
+	}
+
+	return c.filterNeighbours(nh)
+}
+
+// rotationEpoch returns the number of NeighbourRotation periods since the
+// unix epoch, or zero if the rotation is disabled. All the kubenurses compute
+// the same epoch as long as their clocks are synchronized.
+func (c *Checker) rotationEpoch(now time.Time) uint64 {
+	if c.NeighbourRotation <= 0 {
+		return 0
+	}
+
+	return uint64(now.UnixNano() / int64(c.NeighbourRotation)) //nolint:gosec // positive after 1970
+}
+
+// resolveTopology sets the zone and region of the kubenurse and of the
+// neighbours from the labels of their nodes. The zone of the EndpointSlices
+// is kept, and the topology stays empty without the Kubernetes API.
+func (c *Checker) resolveTopology(ctx context.Context, nh []*Neighbour) {
+	if c.client == nil {
+		return
+	}
+
+	c.zone, c.region = c.nodeTopology(ctx, c.currentNode())
+
+	for _, n := range nh {
+		if n.Zone != "" && n.Region != "" {
+			continue
+		}
+
+		zone, region := c.nodeTopology(ctx, n.NodeName)
+		if n.Zone == "" {
+			n.Zone = zone
+		}
+
+		n.Region = region
+	}
+}
+
+func (c *Checker) nodeTopology(ctx context.Context, nodeName string) (zone, region string) {
+	if nodeName == "" {
+		return "", ""
+	}
+
+	node := v1.Node{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: nodeName}, &node); err != nil {
+		return "", ""
+	}
+
+	return node.Labels[v1.LabelTopologyZone], node.Labels[v1.LabelTopologyRegion]
+}
+
+// filterNeighboursByZone guarantees that the paths to the other zones and
+// regions are checked, which the hash strategy only does by chance. Within
+// every zone and region, the neighbours are picked by hash distance like the
+// hash strategy, so that the incoming checks stay evenly distributed. The
+// number of selected neighbours can exceed NeighbourLimit if there are many
+// zones or regions.
+func (c *Checker) filterNeighboursByZone(nh []*Neighbour, epoch uint64) []*Neighbour {
+	ordered := c.orderByHashDistance(nh, epoch)
+	selected := make([]*Neighbour, 0, c.NeighbourLimit)
+	picked := make(map[*Neighbour]bool, c.NeighbourLimit)
+	quotas := make(map[string]int)
+
+	for _, n := range ordered {
+		var group string
+
+		limit := 0
+
+		switch {
+		case n.Region == c.region && n.Zone == c.zone:
+			group, limit = "", c.NeighbourTopology.SameZone
+		case n.Region == c.region:
+			group, limit = "zone/"+n.Zone, c.NeighbourTopology.PerZone
+		default:
+			group, limit = "region/"+n.Region, c.NeighbourTopology.PerRegion
+		}
+
+		if quotas[group] < limit {
+			quotas[group]++
+			picked[n] = true
+			selected = append(selected, n)
+		}
+	}
+
+	for _, n := range ordered {
+		if len(selected) >= c.NeighbourLimit {
+			break
+		}
+
+		if !picked[n] {
+			selected = append(selected, n)
+		}
+	}
+
+	return selected
+}
+
+// orderByHashDistance sorts the neighbours like filterNeighbours, closest
+// first by the distance from the hash of the current node. The order is
+// rotated by NeighbourLimit positions on every epoch, so that all the
+// kubenurses shift their window on the same ring of hashes: every kubenurse
+// still gets NeighbourLimit incoming checks, and every pair of nodes is
+// checked within len(nh)/NeighbourLimit epochs, rounded up.
+func (c *Checker) orderByHashDistance(nh []*Neighbour, epoch uint64) []*Neighbour {
+	currentNodeHash := sha256Uint64(c.currentNode())
+
+	ordered := slices.Clone(nh)
+	slices.SortFunc(ordered, func(a, b *Neighbour) int {
+		da, db := a.NodeHash-currentNodeHash, b.NodeHash-currentNodeHash
+
+		switch {
+		case da < db:
+			return -1
+		case da > db:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	if len(ordered) == 0 || epoch == 0 {
+		return ordered
+	}
+
+	n := uint64(len(ordered))
+	offset := (epoch % n) * uint64(c.NeighbourLimit) % n //nolint:gosec // NeighbourLimit is validated
+
+	return slices.Concat(ordered[offset:], ordered[:offset])
+}
+
+// withTopologyLabels adds the zones of the source and the destination to the
+// metrics of the path check of a neighbour, with the zone strategy.
+func (c *Checker) withTopologyLabels(ctx context.Context, n *Neighbour) context.Context {
+	if c.NeighbourSelection != SelectionZone {
+		return ctx
+	}
+
+	return context.WithValue(ctx, kubenurseLabelsKey{}, []string{"src_zone", c.zone, "dst_zone", n.Zone})
+}
+
+// metricLabels returns the additional labels of the metrics of a check.
+func metricLabels(ctx context.Context) []string {
+	l, _ := ctx.Value(kubenurseLabelsKey{}).([]string)
+	return l
+}
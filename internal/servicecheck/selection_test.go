@@ -0,0 +1,141 @@
+package servicecheck
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestZoneSelection(t *testing.T) {
+	r := require.New(t)
+
+	// three nodes in every zone, the zones a, b and c are in the region
+	// r1, and the zone d in the region r2
+	topology := map[string]string{"a": "r1", "b": "r1", "c": "r1", "d": "r2"}
+
+	var (
+		nodes []runtime.Object
+		nh    []*Neighbour
+	)
+
+	for zone, region := range topology {
+		for i := range 3 {
+			name := fmt.Sprintf("node-%s%d", zone, i)
+			nodes = append(nodes, &v1.Node{ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					v1.LabelTopologyZone:   zone,
+					v1.LabelTopologyRegion: region,
+				},
+			}})
+
+			if name != "node-a0" {
+				nh = append(nh, &Neighbour{NodeName: name, NodeHash: sha256Uint64(name)})
+			}
+		}
+	}
+
+	checker := Checker{
+		client:             fake.NewFakeClient(nodes...),
+		NeighbourLimit:     4,
+		NeighbourSelection: SelectionZone,
+		NeighbourTopology:  NeighbourTopology{SameZone: 1, PerZone: 1, PerRegion: 1},
+		NodeName:           "node-a0",
+	}
+
+	zones := func(nh []*Neighbour) map[string]int {
+		m := make(map[string]int)
+		for _, n := range nh {
+			m[n.Zone]++
+		}
+
+		return m
+	}
+
+	t.Run("one neighbour per zone", func(t *testing.T) {
+		selected := checker.selectNeighbours(context.Background(), nh)
+
+		r.Equal("a", checker.zone)
+		r.Equal("r1", checker.region)
+		r.Equal(map[string]int{"a": 1, "b": 1, "c": 1, "d": 1}, zones(selected))
+
+		for _, n := range selected {
+			r.Equal(topology[n.Zone], n.Region)
+		}
+	})
+
+	t.Run("filled up to the limit", func(t *testing.T) {
+		checker.NeighbourLimit = 6
+		selected := checker.selectNeighbours(context.Background(), nh)
+
+		r.Len(selected, 6)
+
+		for zone, count := range zones(selected) {
+			r.GreaterOrEqual(count, 1, zone)
+		}
+	})
+
+	t.Run("quotas above the limit", func(t *testing.T) {
+		checker.NeighbourLimit = 2
+		checker.NeighbourTopology = NeighbourTopology{SameZone: 2, PerZone: 0, PerRegion: 2}
+		selected := checker.selectNeighbours(context.Background(), nh)
+
+		r.Equal(map[string]int{"a": 2, "d": 2}, zones(selected))
+	})
+
+	t.Run("topology labels", func(t *testing.T) {
+		ctx := checker.withTopologyLabels(context.Background(), nh[0])
+		r.Equal([]string{"src_zone", "a", "dst_zone", nh[0].Zone}, metricLabels(ctx))
+
+		checker.NeighbourSelection = SelectionHash
+		r.Empty(metricLabels(checker.withTopologyLabels(context.Background(), nh[0])))
+	})
+}
+
+func TestRotatingSelection(t *testing.T) {
+	r := require.New(t)
+
+	n, neighbourLimit := 20, 3
+	nodes := generateNeighbours(n)
+
+	checker := Checker{
+		NeighbourLimit:    neighbourLimit,
+		NeighbourRotation: time.Minute,
+	}
+
+	r.Zero((&Checker{}).rotationEpoch(time.Now()))
+	r.Equal(uint64(2), checker.rotationEpoch(time.Unix(150, 0)))
+
+	// every pair is checked within (n-1)/neighbourLimit epochs, rounded up
+	epochs := uint64((n - 1 + neighbourLimit - 1) / neighbourLimit)
+	checked := make(map[[2]string]bool)
+
+	for epoch := uint64(1000); epoch < 1000+epochs; epoch++ {
+		incoming := make(map[string]int, n)
+
+		for _, self := range nodes {
+			checker.NodeName = self.NodeName
+			nh := slices.DeleteFunc(slices.Clone(nodes), func(n *Neighbour) bool { return n == self })
+
+			selected := checker.orderByHashDistance(nh, epoch)[:neighbourLimit]
+			for _, neigh := range selected {
+				incoming[neigh.NodeName]++
+				checked[[2]string{self.NodeName, neigh.NodeName}] = true
+			}
+		}
+
+		for node, count := range incoming {
+			r.Equal(neighbourLimit, count, "%s didn't receive exactly NEIGHBOUR_LIMIT checks in epoch %d", node, epoch)
+		}
+	}
+
+	r.Len(checked, n*(n-1))
+}
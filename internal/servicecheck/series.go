@@ -0,0 +1,118 @@
+package servicecheck
+
+import (
+	"context"
+	"sync"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/postfinance/kubenurse/internal/util"
+)
+
+// pairSeries keeps the names of the series labelled with a destination node,
+// so that the series of the neighbours which are no longer checked can be
+// unregistered instead of exporting their last value forever.
+type pairSeries struct {
+	mu sync.Mutex
+	// names of the series, by destination node
+	names map[string]map[string]bool
+	// checked are the destination nodes checked since the last sweep
+	checked map[string]bool
+}
+
+// add records the series name of a destination node, which is checked.
+func (p *pairSeries) add(dstNode, name string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.names == nil {
+		p.names, p.checked = make(map[string]map[string]bool), make(map[string]bool)
+	}
+
+	if p.names[dstNode] == nil {
+		p.names[dstNode] = make(map[string]bool)
+	}
+
+	p.names[dstNode][name] = true
+	p.checked[dstNode] = true
+
+	return name
+}
+
+// sweep unregisters the series of the destination nodes which were not
+// checked since the last sweep.
+func (p *pairSeries) sweep() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for node, names := range p.names {
+		if p.checked[node] {
+			continue
+		}
+
+		for name := range names {
+			metrics.UnregisterMetric(name)
+		}
+
+		delete(p.names, node)
+	}
+
+	clear(p.checked)
+}
+
+// remove unregisters the series name of a destination node, which has no
+// value anymore.
+func (p *pairSeries) remove(dstNode, name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	metrics.UnregisterMetric(name)
+	delete(p.names[dstNode], name)
+}
+
+// pairGauge returns the gauge of a metric labelled with the source and
+// destination nodes, which is unregistered once dstNode isn't checked anymore.
+func (c *Checker) pairGauge(dstNode, name string, labels ...string) *metrics.Gauge {
+	return metrics.GetOrCreateGauge(c.pairSeries.add(dstNode, util.GenMetricsName(name, labels...)), nil)
+}
+
+// pairCounter is the counter equivalent of pairGauge.
+func (c *Checker) pairCounter(dstNode, name string, labels ...string) *metrics.Counter {
+	return metrics.GetOrCreateCounter(c.pairSeries.add(dstNode, util.GenMetricsName(name, labels...)))
+}
+
+// dropPairSeries unregisters the series of a metric labelled with the source
+// and destination nodes, e.g. a gauge without a value in the last run.
+func (c *Checker) dropPairSeries(dstNode, name string, labels ...string) {
+	c.pairSeries.remove(dstNode, util.GenMetricsName(name, labels...))
+}
+
+// dstSeries is the destination of the checks of a context, whose series are
+// tracked by series.
+type dstSeries struct {
+	series  *pairSeries
+	dstNode string
+}
+
+// withDstNode tracks the series of the checks of ctx as the series of
+// dstNode, e.g. the counters and histograms of the path_<node> type, so that
+// they are unregistered once dstNode isn't checked anymore.
+func (c *Checker) withDstNode(ctx context.Context, dstNode string) context.Context {
+	return context.WithValue(ctx, kubenurseSeriesKey{}, &dstSeries{series: &c.pairSeries, dstNode: dstNode})
+}
+
+// withDNSServer tracks the series of the checks of ctx as the series of the
+// DNS server ip, so that they are unregistered once the server is gone, e.g.
+// a rescheduled CoreDNS pod.
+func (c *Checker) withDNSServer(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, kubenurseSeriesKey{}, &dstSeries{series: &c.dnsSeries, dstNode: ip})
+}
+
+// trackSeries records the series name as one of the destination node of ctx,
+// if there is one, and returns it.
+func trackSeries(ctx context.Context, name string) string {
+	if d, ok := ctx.Value(kubenurseSeriesKey{}).(*dstSeries); ok {
+		return d.series.add(d.dstNode, name)
+	}
+
+	return name
+}
@@ -64,31 +64,56 @@ func New(cl client.Client, allowUnschedulable bool, cacheTTL time.Duration, hist
 		allowUnschedulable: allowUnschedulable,
 		client:             cl,
 		httpClient:         httpClient,
+		tlsConfig:          tlsConfig,
+		histogramGetter:    histogramGetter,
 		cacheTTL:           cacheTTL,
-		ExtraChecks:        make(map[string]string),
+		ExtraChecks:        make(map[string]ExtraCheck),
+		DNSTimeout:         2 * time.Second,
+		HostPathPort:       8082,
+		KubeletPort:        10248,
+		KubeProxyPort:      10256,
+		UDPPort:            8081,
+		UDPProbes:          10,
+		UDPTimeout:         time.Second,
+		extraChecksState:   make(map[string]*extraCheckState),
 	}, nil
 }
 
 // Run runs all servicechecks and returns the result togeter with a boolean which indicates success. The cache
 // is respected.
 func (c *Checker) Run(ctx context.Context) {
+	// The trace of the run holds a span per check, it ends after the results
+	// were cached
+	ctx, span := c.tracer().Start(ctx, "run")
+	defer span.End()
+
 	// Run Checks
 	result := sync.Map{}
 
 	wg := sync.WaitGroup{}
 
+	last := c.LastResults()
+
+	var discovered []*Neighbour
+
 	// Cache result (used for /alive handler)
 	defer func() {
-		res := make(map[string]any)
+		snapshot := Snapshot{
+			Checks:        make(map[string]Result),
+			Node:          c.currentNode(),
+			Neighbourhood: discovered,
+			Timestamp:     time.Now(),
+		}
 
 		result.Range(func(key, value any) bool {
 			k, _ := key.(string)
-			res[k] = value
+			snapshot.Checks[k], _ = value.(Result)
 
 			return true
 		})
 
-		c.LastCheckResult = res
+		c.lastSnapshot.Store(&snapshot)
+		c.history.add(&snapshot, c.HistoryDepth)
 	}()
 
 	wg.Add(4)
@@ -98,43 +123,118 @@ func (c *Checker) Run(ctx context.Context) {
 	go c.measure(ctx, &wg, &result, c.MeIngress, meIngress)
 	go c.measure(ctx, &wg, &result, c.MeService, meService)
 
-	wg.Add(len(c.ExtraChecks))
+	extraChecks := c.extraChecks(ctx)
+	defer c.reportClusterChecks(ctx, extraChecks)
+
+	// drop the series of the neighbours and DNS servers which were not
+	// checked, unless they could not be discovered
+	discoveryFailed, dnsFailed := false, false
+
+	defer func() {
+		if !discoveryFailed {
+			c.pairSeries.sweep()
+		}
+
+		if !dnsFailed {
+			c.dnsSeries.sweep()
+		}
+	}()
+
+	// wait for all checks before reporting and caching the results, also
+	// when returning early because of the neighbourhood
+	defer wg.Wait()
+
+	for name, ec := range extraChecks {
+		if !c.extraCheckDue(name, ec) {
+			if res, ok := last.check(name); ok {
+				result.Store(name, res)
+			}
+
+			continue
+		}
+
+		wg.Add(1)
 
-	for metricName, url := range c.ExtraChecks {
 		go c.measure(ctx, &wg, &result,
-			func(ctx context.Context) string { return c.doRequest(ctx, url, false) },
-			metricName)
+			func(ctx context.Context) string { return c.runExtraCheck(ctx, name, ec) },
+			name)
+	}
+
+	if c.CheckDNS {
+		dnsFailed = !c.runDNSChecks(ctx, &wg, &result)
+	}
+
+	if c.CheckKubelet || c.CheckKubeProxy {
+		c.runLocalNodeHealthChecks(ctx, &wg, &result)
 	}
 
 	if c.SkipCheckNeighbourhood {
-		result.Store(NeighbourhoodState, skippedStr)
+		result.Store(NeighbourhoodState, newResult(skippedStr))
 		return
 	}
 
-	neighbours, err := c.getNeighbours(ctx, c.KubenurseNamespace, c.NeighbourFilter)
+	neighbours, err := c.Neighbours(ctx)
 	if err != nil {
-		result.Store(NeighbourhoodState, err.Error())
+		result.Store(NeighbourhoodState, newResult(err.Error()))
+
+		discoveryFailed = true
+
 		return
 	}
 
-	result.Store(NeighbourhoodState, okStr)
-	result.Store(Neighbourhood, neighbours)
+	result.Store(NeighbourhoodState, newResult(okStr))
 
-	if c.NeighbourLimit > 0 && len(neighbours) > c.NeighbourLimit {
-		neighbours = c.filterNeighbours(neighbours)
-	}
+	discovered = neighbours
+
+	neighbours = c.selectNeighbours(ctx, neighbours)
 
 	wg.Add((len(neighbours)))
 
 	for _, neighbour := range neighbours {
 		check := func(ctx context.Context) string {
-			return c.doRequest(ctx, podIPtoURL(neighbour.PodIP, c.UseTLS), true)
+			if c.NeighbourBurstProbes > 1 {
+				return c.doNeighbourBurst(ctx, neighbour.url(c.UseTLS), neighbour.NodeName)
+			}
+
+			return c.doRequest(ctx, neighbour.url(c.UseTLS), true)
 		}
 
-		go c.measure(ctx, &wg, &result, check, "path_"+neighbour.NodeName)
+		go c.measure(c.withTopologyLabels(c.withDstNode(ctx, neighbour.NodeName), neighbour), &wg, &result, check, "path_"+neighbour.NodeName)
 	}
 
-	wg.Wait()
+	if c.CheckHostPath {
+		c.runHostPathChecks(ctx, &wg, &result, neighbours)
+	}
+
+	if c.CheckNeighbourNodeHealth {
+		for _, neighbour := range neighbours {
+			c.runNodeHealthChecks(c.withDstNode(ctx, neighbour.NodeName), &wg, &result, neighbour.HostIP, "_"+neighbour.NodeName)
+		}
+	}
+
+	if len(c.MTUSizes) > 0 {
+		wg.Add(len(neighbours))
+
+		for _, neighbour := range neighbours {
+			check := func(ctx context.Context) string {
+				return c.doMTUCheck(ctx, neighbour.url(c.UseTLS), neighbour.NodeName)
+			}
+
+			go c.measure(c.withDstNode(ctx, neighbour.NodeName), &wg, &result, check, "mtu_"+neighbour.NodeName)
+		}
+	}
+
+	if c.CheckUDP {
+		wg.Add(len(neighbours))
+
+		for _, neighbour := range neighbours {
+			check := func(ctx context.Context) string {
+				return c.doUDPCheck(ctx, neighbour.PodIP)
+			}
+
+			go c.measure(c.withDstNode(ctx, neighbour.NodeName), &wg, &result, check, "udp_"+neighbour.NodeName)
+		}
+	}
 }
 
 // APIServerDirect checks the /version endpoint of the Kubernetes API Server through the direct link
@@ -183,15 +283,17 @@ func (c *Checker) measure(ctx context.Context, wg *sync.WaitGroup, res *sync.Map
 	// metrics and errors based with the label
 	defer wg.Done()
 
+	rec := &resultRecorder{}
+
 	ctx = context.WithValue(ctx, kubenurseTypeKey{}, requestType)
 	ctx = context.WithValue(ctx, kubenurseErrorAccountedKey{}, &atomic.Bool{})
-	res.Store(requestType, check(ctx))
-}
+	ctx = context.WithValue(ctx, kubenurseResultKey{}, rec)
 
-func podIPtoURL(podIP string, useTLS bool) string {
-	if useTLS {
-		return "https://" + net.JoinHostPort(podIP, "8443") + "/alwayshappy"
-	}
+	ctx, span := c.tracer().Start(ctx, requestType)
+
+	start := time.Now()
+	result := rec.result(check(ctx), time.Since(start))
 
-	return "http://" + net.JoinHostPort(podIP, "8080") + "/alwayshappy"
+	endCheckSpan(span, result)
+	res.Store(requestType, result)
 }
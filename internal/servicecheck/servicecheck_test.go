@@ -54,10 +54,10 @@ func TestCombined(t *testing.T) {
 	checker.SkipCheckAPIServerDNS = true
 	checker.SkipCheckAPIServerDirect = true
 
-	checker.ExtraChecks = map[string]string{
-		"check_not_found": server.URL + "/not-found",
-		"check_ok":        server.URL + "/ok",
-		"check_ipv6":      "https://ipv6.google.com/",
+	checker.ExtraChecks = map[string]ExtraCheck{
+		"check_not_found": {URL: server.URL + "/not-found"},
+		"check_ok":        {URL: server.URL + "/ok"},
+		"check_ipv6":      {URL: "https://ipv6.google.com/"},
 	}
 	r.NoError(err)
 	r.NotNil(checker)
@@ -66,9 +66,17 @@ func TestCombined(t *testing.T) {
 		r := require.New(t)
 		checker.Run(context.Background())
 
-		r.Equal(okStr, checker.LastCheckResult[NeighbourhoodState])
-		r.Equal(okStr, checker.LastCheckResult["check_ok"])
-		// r.Equal(okStr, checker.LastCheckResult["check_ipv6"]) // gh-action doesn't support IPv6 yet
-		r.Equal("404 Not Found", checker.LastCheckResult["check_not_found"])
+		r.Equal(StatusOK, checker.LastResults().Checks[NeighbourhoodState].Status)
+		r.Equal(StatusOK, checker.LastResults().Checks["check_ok"].Status)
+		// r.Equal(StatusOK, checker.LastResults().Checks["check_ipv6"].Status) // gh-action doesn't support IPv6 yet
+		r.Equal("404 Not Found", checker.LastResults().Checks["check_not_found"].Message)
+
+		notFound := checker.LastResults().Checks["check_not_found"]
+		r.Equal(StatusError, notFound.Status)
+		r.Equal(http.StatusNotFound, notFound.HTTPCode)
+		r.Equal("status_code_404", notFound.ErrorClass)
+		r.Equal(server.URL+"/not-found", notFound.Target)
+		r.Contains(notFound.PhasesSeconds, "got_first_resp_byte")
+		r.Positive(notFound.LatencySeconds)
 	})
 }
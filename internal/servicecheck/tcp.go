@@ -0,0 +1,123 @@
+package servicecheck
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/postfinance/kubenurse/internal/util"
+)
+
+const (
+	tcpReqTotal  = "tcpclient_requests_total"
+	tcpReqDurSec = "tcpclient_request_duration_seconds"
+
+	// tcpCheckTimeout is the timeout of a tcp check, including the TLS
+	// handshake, which is the timeout of the http client.
+	tcpCheckTimeout = dialTimeout + time.Second
+)
+
+// tcpTarget returns the address and whether a TLS handshake is required when
+// url is a tcp://host:port or tls://host:port url.
+func tcpTarget(url string) (addr string, useTLS, ok bool) {
+	if addr, ok = strings.CutPrefix(url, "tcp://"); ok {
+		return strings.TrimSuffix(addr, "/"), false, true
+	}
+
+	if addr, ok = strings.CutPrefix(url, "tls://"); ok {
+		return strings.TrimSuffix(addr, "/"), true, true
+	}
+
+	return "", false, false
+}
+
+// doTCPCheck resolves addr, opens a TCP connection to it and optionally
+// performs a TLS handshake. The durations and errors of every phase are
+// collected with the same metrics and events as the httptrace of the http
+// checks, so both can be compared on the same dashboards.
+func (c *Checker) doTCPCheck(ctx context.Context, addr string, useTLS bool) string {
+	requestType, _ := ctx.Value(kubenurseTypeKey{}).(string)
+	l := []string{"type", requestType}
+
+	// the whole check is bound like the requests of the http client, so that
+	// a server which never answers the handshake cannot block the run
+	ctx, cancel := context.WithTimeout(ctx, tcpCheckTimeout)
+	defer cancel()
+
+	rec := recorderFrom(ctx)
+	rec.startRequest(addr)
+
+	start := time.Now()
+
+	phase := func(event string, err error) {
+		if err != nil {
+			rec.fail(event)
+			metrics.GetOrCreateCounter(util.GenMetricsName(errCounter, append(l, "event", event)...)).Inc()
+			metrics.GetOrCreateCounter(util.GenMetricsName(tcpReqTotal, append(l, "result", event)...)).Inc()
+			slog.Error("request failure in tcp check", "event_type", event, "request_type", requestType, "err", err)
+
+			return
+		}
+
+		rec.phase(event, time.Since(start))
+		c.histogramGetter(util.GenMetricsName(hcTraceReqDurSec, append(l, "event", event)...)).UpdateDuration(start)
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		phase("invalid_address", err)
+		return err.Error()
+	}
+
+	ips := []string{host}
+
+	if net.ParseIP(host) == nil {
+		ips, err = net.DefaultResolver.LookupHost(ctx, host)
+		phase("dns_done", err)
+
+		if err != nil {
+			return err.Error()
+		}
+	}
+
+	dialer := net.Dialer{Timeout: dialTimeout}
+
+	var conn net.Conn
+
+	for _, ip := range ips { // try every address, as the http transport does
+		conn, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, port))
+		if err == nil {
+			break
+		}
+	}
+
+	phase("connect_done", err)
+
+	if err != nil {
+		return err.Error()
+	}
+
+	defer conn.Close()
+
+	if useTLS {
+		tlsConfig := c.tlsConfig.Clone()
+		tlsConfig.ServerName = host
+
+		tlsConn := tls.Client(conn, tlsConfig)
+		err = tlsConn.HandshakeContext(ctx)
+		phase("tls_handshake_done", err)
+
+		if err != nil {
+			return err.Error()
+		}
+	}
+
+	metrics.GetOrCreateCounter(util.GenMetricsName(tcpReqTotal, append(l, "result", okStr)...)).Inc()
+	c.histogramGetter(util.GenMetricsName(tcpReqDurSec, l...)).UpdateDuration(start)
+
+	return okStr
+}
@@ -0,0 +1,128 @@
+package servicecheck
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestTCPCheck(t *testing.T) {
+	r := require.New(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	tlsServer := httptest.NewTLSServer(handler)
+	defer tlsServer.Close()
+
+	// a listener which is closed right away, to get a port refusing connections
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	r.NoError(err)
+	r.NoError(closed.Close())
+
+	checker, err := New(fake.NewFakeClient(), false, 3*time.Second, func(s string) Histogram {
+		return metrics.GetOrCreatePrometheusHistogram(s)
+	})
+	r.NoError(err)
+
+	checker.tlsConfig.RootCAs = x509.NewCertPool()
+	checker.tlsConfig.RootCAs.AddCert(tlsServer.Certificate())
+
+	checker.SkipCheckAPIServerDNS = true
+	checker.SkipCheckAPIServerDirect = true
+	checker.SkipCheckMeIngress = true
+	checker.SkipCheckMeService = true
+	checker.SkipCheckNeighbourhood = true
+
+	checker.ExtraChecks = map[string]ExtraCheck{
+		"tcp_ok":        {URL: "tcp://" + server.Listener.Addr().String()},
+		"tls_ok":        {URL: "tls://" + tlsServer.Listener.Addr().String()},
+		"tls_plaintext": {URL: "tls://" + server.Listener.Addr().String()},
+		"tcp_refused":   {URL: "tcp://" + closed.Addr().String()},
+		"tcp_no_port":   {URL: "tcp://localhost"},
+	}
+
+	checker.Run(context.Background())
+
+	r.Equal(StatusOK, checker.LastResults().Checks["tcp_ok"].Status)
+	r.Equal(StatusOK, checker.LastResults().Checks["tls_ok"].Status)
+	r.NotEqual(StatusOK, checker.LastResults().Checks["tls_plaintext"].Status)
+	r.Contains(checker.LastResults().Checks["tcp_refused"].Message, "connection refused")
+	r.Contains(checker.LastResults().Checks["tcp_no_port"].Message, "missing port")
+
+	var sb strings.Builder
+
+	metrics.WritePrometheus(&sb, false)
+	r.Contains(sb.String(), `kubenurse_errors_total{type="tcp_refused",event="connect_done"} 1`)
+	r.Contains(sb.String(), `kubenurse_errors_total{type="tls_plaintext",event="tls_handshake_done"} 1`)
+	r.Contains(sb.String(), `kubenurse_tcpclient_requests_total{type="tls_ok",result="ok"} 1`)
+	r.Contains(sb.String(), `kubenurse_httpclient_trace_request_duration_seconds_count{type="tcp_ok",event="connect_done"} 1`)
+}
+
+func TestTCPCheckSilentServer(t *testing.T) {
+	r := require.New(t)
+
+	// a server which accepts the connections, but never answers the handshake
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	r.NoError(err)
+
+	defer silent.Close()
+
+	go func() {
+		var conns []net.Conn
+
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+
+			conns = append(conns, conn) // kept open without answering
+		}
+	}()
+
+	checker, err := New(fake.NewFakeClient(), false, 3*time.Second, func(s string) Histogram {
+		return metrics.GetOrCreatePrometheusHistogram(s)
+	})
+	r.NoError(err)
+
+	checker.SkipCheckAPIServerDNS = true
+	checker.SkipCheckAPIServerDirect = true
+	checker.SkipCheckMeIngress = true
+	checker.SkipCheckMeService = true
+	checker.SkipCheckNeighbourhood = true
+	checker.ExtraChecks = map[string]ExtraCheck{"tls_silent": {URL: "tls://" + silent.Addr().String()}}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		checker.Run(context.Background())
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * tcpCheckTimeout):
+		r.FailNow("the check of a silent server must time out")
+	}
+
+	r.Contains(checker.LastResults().Checks["tls_silent"].Message, "context deadline exceeded")
+}
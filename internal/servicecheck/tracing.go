@@ -0,0 +1,115 @@
+package servicecheck
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// TracerName is the instrumentation scope of the spans of the kubenurse.
+const TracerName = "github.com/postfinance/kubenurse"
+
+// tracer returns the tracer of the checks, which discards the spans if no
+// TracerProvider is configured.
+func (c *Checker) tracer() trace.Tracer {
+	if c.TracerProvider == nil {
+		return noop.NewTracerProvider().Tracer(TracerName)
+	}
+
+	return c.TracerProvider.Tracer(TracerName)
+}
+
+// endCheckSpan records the result of a check on its span.
+func endCheckSpan(span trace.Span, res Result) {
+	defer span.End()
+
+	if res.Target != "" {
+		span.SetAttributes(semconv.URLFull(res.Target))
+	}
+
+	if res.Status == StatusError {
+		span.SetStatus(codes.Error, res.Message)
+	}
+}
+
+// startRequestSpan starts the client span of a request, as a child of the
+// span of the check in the context of r.
+func startRequestSpan(r *http.Request) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(r.Context()).TracerProvider().Tracer(TracerName)
+
+	return tracer.Start(r.Context(), "HTTP "+r.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLFull(r.URL.Redacted()),
+		),
+	)
+}
+
+// withTraceContext returns a copy of r with the context ctx, and the W3C
+// traceparent header of the span in ctx if r is sent to a kubenurse, so that
+// it can record its server span. The other endpoints, e.g. the extra checks,
+// don't get the trace context.
+func withTraceContext(ctx context.Context, r *http.Request) *http.Request {
+	r = r.WithContext(ctx)
+
+	propagate, _ := ctx.Value(kubenurseTraceContextKey{}).(bool)
+	if propagate && trace.SpanContextFromContext(ctx).IsValid() {
+		r.Header = r.Header.Clone() // a round tripper must not modify the request
+		propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(r.Header))
+	}
+
+	return r
+}
+
+// phaseSpans records the httptrace phases of a request, e.g. the DNS lookup
+// or the TLS handshake, as child spans of the request span. The phases are
+// reported by the hooks of the http.Transport, possibly from several
+// goroutines.
+type phaseSpans struct {
+	ctx context.Context
+
+	mu     sync.Mutex
+	starts map[string]time.Time
+}
+
+func newPhaseSpans(ctx context.Context) *phaseSpans {
+	return &phaseSpans{ctx: ctx, starts: make(map[string]time.Time)}
+}
+
+func (p *phaseSpans) start(phase string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.starts[phase] = time.Now()
+}
+
+// end records the span of the phase, if it was started, e.g. the concurrent
+// dials to the IPv4 and IPv6 addresses are only recorded once.
+func (p *phaseSpans) end(phase string, err error) {
+	p.mu.Lock()
+	start, ok := p.starts[phase]
+	delete(p.starts, phase)
+	p.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	tracer := trace.SpanFromContext(p.ctx).TracerProvider().Tracer(TracerName)
+
+	_, span := tracer.Start(p.ctx, phase, trace.WithTimestamp(start))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
@@ -19,19 +20,37 @@ const (
 	k8sCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
 )
 
-// doRequest does an http request only to get the http status code
+// doRequest does an http GET request only to get the http status code
 func (c *Checker) doRequest(ctx context.Context, url string, addOriginHeader bool) string {
-	// Read Bearer Token file from ServiceAccount
-	token, err := os.ReadFile(K8sTokenFile)
-	if !testing.Testing() && err != nil {
-		slog.Error("error in doRequest while reading k8sTokenFile", "err", err)
-		return errStr
+	// only the kubenurses, which record the server span of their /alwayshappy
+	// endpoint, get the trace context, not the API server nor the node
+	// components
+	if strings.HasSuffix(url, "/alwayshappy") {
+		ctx = context.WithValue(ctx, kubenurseTraceContextKey{}, true)
 	}
 
-	req, _ := http.NewRequestWithContext(ctx, "GET", url, http.NoBody)
+	return c.doCheckRequest(ctx, http.MethodGet, url, http.StatusOK, addOriginHeader)
+}
+
+// doCheckRequest does an http request and checks that the response has the expected status code
+func (c *Checker) doCheckRequest(ctx context.Context, method, url string, expectedStatus int, addOriginHeader bool) string {
+	ctx = context.WithValue(ctx, kubenurseExpectedStatusKey{}, expectedStatus)
+
+	req, err := http.NewRequestWithContext(ctx, method, url, http.NoBody)
+	if err != nil {
+		return err.Error()
+	}
 
-	// Only add the Bearer for API Server Requests
+	// Only add the Bearer for API Server Requests, the token doesn't exist
+	// in the standalone mode
 	if strings.HasSuffix(url, "/version") {
+		// Read Bearer Token file from ServiceAccount
+		token, err := os.ReadFile(K8sTokenFile)
+		if !testing.Testing() && err != nil {
+			slog.Error("error in doRequest while reading k8sTokenFile", "err", err)
+			return errStr
+		}
+
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	}
 
@@ -48,7 +67,7 @@ func (c *Checker) doRequest(ctx context.Context, url string, addOriginHeader boo
 	// Body is non-nil if err is nil, so close it
 	_ = resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
+	if resp.StatusCode == expectedStatus {
 		return okStr
 	}
 
@@ -63,13 +82,14 @@ func generateTLSConfig(extraCA string) (*tls.Config, error) {
 		rootCAs = x509.NewCertPool()
 	}
 
-	// Append ServiceAccount cacert
+	// Append ServiceAccount cacert, which doesn't exist in the standalone mode
 	caCert, err := os.ReadFile(k8sCAFile)
-	if err != nil {
-		return nil, fmt.Errorf("could not load certificate %s: %w", k8sCAFile, err)
-	}
 
-	if ok := rootCAs.AppendCertsFromPEM(caCert); !ok {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("could not load certificate %s: %w", k8sCAFile, err)
+	case !rootCAs.AppendCertsFromPEM(caCert):
 		return nil, errors.New("could not append ca cert to system certpool")
 	}
 
@@ -2,19 +2,26 @@ package servicecheck
 
 import (
 	"context"
+	"crypto/tls"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
 	NeighbourhoodState = "neighbourhood_state"
+	DNSState           = "dns_state"
 	Neighbourhood      = "neighbourhood"
 	meService          = "me_service"
 	meIngress          = "me_ingress"
 	APIServerDirect    = "api_server_direct"
 	APIServerDNS       = "api_server_dns"
+	Kubelet            = "kubelet"
+	KubeProxy          = "kube_proxy"
 )
 
 // Checker implements the kubenurse checker
@@ -35,6 +42,11 @@ type Checker struct {
 	SkipCheckAPIServerDirect bool
 	SkipCheckAPIServerDNS    bool
 
+	// NodeName is the node the kubenurse runs on. If it is empty, the node is
+	// found during the neighbours discovery
+	NodeName       string
+	discoveredNode atomic.Pointer[string]
+
 	// Neighbourhood
 	KubenurseNamespace     string
 	NeighbourFilter        string
@@ -42,8 +54,67 @@ type Checker struct {
 	allowUnschedulable     bool
 	SkipCheckNeighbourhood bool
 
+	// Discovery finds the neighbours, the kubenurse pods in KubenurseNamespace
+	// matching NeighbourFilter are listed if it is nil
+	Discovery NeighbourDiscovery
+
+	// NeighbourSelection is the strategy used to select NeighbourLimit
+	// neighbours, SelectionHash if empty
+	NeighbourSelection string
+	NeighbourTopology  NeighbourTopology
+	zone, region       string
+
+	// NeighbourRotation is the period after which the selected neighbours
+	// change, so that all the paths are checked over time, the selection
+	// is fixed if it is zero
+	NeighbourRotation time.Duration
+
+	// NeighbourBurstProbes is the number of requests sent to every neighbour
+	// on each run, a burst is only sent if it is greater than one
+	NeighbourBurstProbes int
+	NeighbourBurstGap    time.Duration
+
+	// DNS
+	CheckDNS            bool
+	DNSQueries          []DNSQuery
+	DNSServiceNamespace string
+	DNSServiceName      string
+	DNSTimeout          time.Duration
+
+	// Node components
+	CheckKubelet             bool
+	KubeletPort              int
+	CheckKubeProxy           bool
+	KubeProxyPort            int
+	CheckNeighbourNodeHealth bool
+
+	// CheckHostPath enables the check of the neighbours through their node IP
+	CheckHostPath bool
+	HostPathPort  int
+
+	// MTUSizes are the payload sizes sent to every neighbour by the MTU check,
+	// which is disabled if empty
+	MTUSizes   []int
+	MTUTimeout time.Duration
+
+	// UDP echo between neighbours
+	CheckUDP   bool
+	UDPPort    int
+	UDPProbes  int
+	UDPTimeout time.Duration
+
 	// Additional endpoints
-	ExtraChecks map[string]string
+	ExtraChecks map[string]ExtraCheck
+
+	// ClusterChecks enables the extra checks defined with KubenurseCheck resources
+	ClusterChecks bool
+
+	// extraChecksState keeps track of the extra checks results between runs
+	extraChecksState   map[string]*extraCheckState
+	extraChecksStateMu sync.Mutex
+
+	// lastClusterChecksReport is the last time the KubenurseCheck statuses were updated
+	lastClusterChecksReport time.Time
 
 	// TLS
 	UseTLS bool
@@ -54,11 +125,52 @@ type Checker struct {
 	// Http Client for https requests
 	httpClient *http.Client
 
-	// LastCheckResult represents a cached check result
-	LastCheckResult map[string]any
+	// tlsConfig is used for the TLS handshake of the tcp checks
+	tlsConfig *tls.Config
+
+	// histogramGetter returns the histogram for the given metric name
+	histogramGetter func(string) Histogram
+
+	// lastSnapshot holds the results of the last run
+	lastSnapshot atomic.Pointer[Snapshot]
+
+	// HistoryDepth is the number of past results kept per check, the history
+	// is disabled if it is zero
+	HistoryDepth int
+	history      history
+
+	// pairSeries are the series of the checked pairs of nodes
+	pairSeries pairSeries
+	// dnsSeries are the series of the checked DNS servers, by IP
+	dnsSeries pairSeries
 
 	// cacheTTL defines the TTL of how long a cached result is valid
 	cacheTTL time.Duration
+
+	// TracerProvider records a trace of every run, with a span per check,
+	// the spans are discarded if it is nil
+	TracerProvider trace.TracerProvider
+}
+
+// ExtraCheck is an additional endpoint checked by the kubenurse. It is
+// either configured statically or defined with a KubenurseCheck resource.
+type ExtraCheck struct {
+	// URL is either an http(s) url, or a tcp://host:port or tls://host:port
+	// url for a TCP connect check, optionally followed by a TLS handshake.
+	URL string
+	// Method defaults to GET
+	Method string
+	// ExpectedStatus defaults to 200
+	ExpectedStatus int
+	// Interval is the minimum duration between two checks, zero means every run
+	Interval time.Duration
+	// Timeout of the request, zero means the http client timeout
+	Timeout time.Duration
+	// NodeSelector restricts the check to nodes with matching labels
+	NodeSelector map[string]string
+
+	// resource is the KubenurseCheck which defines the check, if any
+	resource client.ObjectKey
 }
 
 // Check is the signature used by all checks that the checker can execute.
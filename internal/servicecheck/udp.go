@@ -0,0 +1,190 @@
+package servicecheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/postfinance/kubenurse/internal/util"
+)
+
+const (
+	udpRTTSec        = "udp_rtt_seconds"
+	udpLossPercent   = "udp_loss_percent"
+	udpReorderedPkts = "udp_reordered_packets_total"
+	udpProbeGap      = 10 * time.Millisecond
+	udpProbeSize     = 20 // magic (4) + run id (4) + sequence (4) + send time (8)
+)
+
+// udpMagic prefixes every probe datagram, so that unrelated datagrams are ignored.
+//
+//nolint:gochecknoglobals // constant byte slice
+var udpMagic = []byte("KNUE")
+
+// ServeUDPEcho sends the probe datagrams received on conn back to their
+// sender, until conn is closed. Any other datagram is dropped, so that the
+// listener cannot be used to reflect or amplify traffic.
+func ServeUDPEcho(conn net.PacketConn) error {
+	buf := make([]byte, udpProbeSize+1) // a longer datagram is read truncated
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			return err
+		}
+
+		if n != udpProbeSize || !bytes.Equal(buf[:len(udpMagic)], udpMagic) {
+			continue
+		}
+
+		if _, err := conn.WriteTo(buf[:n], addr); err != nil {
+			slog.Debug("cannot echo udp datagram", "addr", addr, "err", err)
+		}
+	}
+}
+
+// udpReply is a probe datagram echoed by a neighbour.
+type udpReply struct {
+	seq uint32
+	rtt time.Duration
+}
+
+// doUDPCheck sends UDPProbes sequenced datagrams to the UDP echo listener of
+// a neighbour, and records the round-trip time of every echoed datagram, the
+// percentage of lost datagrams and the number of datagrams received out of
+// order. The check only fails if all the datagrams are lost, partial loss is
+// reported with the metrics.
+func (c *Checker) doUDPCheck(ctx context.Context, ip string) string {
+	requestType, _ := ctx.Value(kubenurseTypeKey{}).(string)
+	l := []string{"type", requestType}
+
+	addr := net.JoinHostPort(ip, strconv.Itoa(c.UDPPort))
+	rec := recorderFrom(ctx)
+	rec.startRequest(addr)
+
+	fail := func(event string, err error) string {
+		rec.fail(event)
+		metrics.GetOrCreateCounter(trackSeries(ctx, util.GenMetricsName(errCounter, append(l, "event", event)...))).Inc()
+		slog.Error("request failure in udp check", "event_type", event, "request_type", requestType, "err", err)
+
+		return err.Error()
+	}
+
+	dialer := net.Dialer{}
+
+	conn, err := dialer.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return fail("dial", err)
+	}
+	defer conn.Close()
+
+	probes := max(c.UDPProbes, 1)
+	runID := rand.Uint32() //nolint:gosec // not used for security purposes
+
+	// the echoed datagrams are read until all of them were received, or
+	// UDPTimeout after the last one was sent
+	_ = conn.SetReadDeadline(time.Now().Add(time.Duration(probes)*udpProbeGap + c.UDPTimeout))
+
+	repliesc := make(chan []udpReply, 1)
+	go func() { repliesc <- readUDPReplies(conn, runID, probes) }()
+
+	var writeErr error
+
+	msg := make([]byte, udpProbeSize)
+
+	for seq := range probes {
+		copy(msg, udpMagic)
+		binary.BigEndian.PutUint32(msg[4:], runID)
+		binary.BigEndian.PutUint32(msg[8:], uint32(seq))                    //nolint:gosec // probes is small
+		binary.BigEndian.PutUint64(msg[12:], uint64(time.Now().UnixNano())) //nolint:gosec // always positive
+
+		// a failed write, e.g. because an ICMP port unreachable was received
+		// for a previous datagram, is accounted as a lost datagram
+		if _, err := conn.Write(msg); err != nil {
+			writeErr = err
+		}
+
+		if seq < probes-1 {
+			time.Sleep(udpProbeGap)
+		}
+	}
+
+	replies := <-repliesc
+
+	reordered := 0
+	maxSeq := -1
+
+	for _, r := range replies {
+		c.histogramGetter(trackSeries(ctx, util.GenMetricsName(udpRTTSec, l...))).Update(r.rtt.Seconds())
+
+		if int(r.seq) < maxSeq {
+			reordered++
+		}
+
+		maxSeq = max(maxSeq, int(r.seq))
+	}
+
+	loss := 100 * float64(probes-len(replies)) / float64(probes)
+
+	metrics.GetOrCreateGauge(trackSeries(ctx, util.GenMetricsName(udpLossPercent, l...)), nil).Set(loss)
+	metrics.GetOrCreateCounter(trackSeries(ctx, util.GenMetricsName(udpReorderedPkts, l...))).Add(reordered)
+
+	if len(replies) == 0 {
+		err := fmt.Errorf("none of the %d udp datagrams was echoed", probes)
+		if writeErr != nil {
+			err = fmt.Errorf("%w: %w", err, writeErr)
+		}
+
+		return fail("no_reply", err)
+	}
+
+	return okStr
+}
+
+// readUDPReplies reads the echoed datagrams of the run until the expected
+// number was received or the read deadline of conn is exceeded. Duplicates
+// are ignored.
+func readUDPReplies(conn net.Conn, runID uint32, expected int) []udpReply {
+	var (
+		replies = make([]udpReply, 0, expected)
+		seen    = make(map[uint32]bool, expected)
+		buf     = make([]byte, 1500)
+	)
+
+	for len(replies) < expected {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return replies
+		}
+
+		received := time.Now()
+
+		if n != udpProbeSize || !bytes.Equal(buf[:4], udpMagic) || binary.BigEndian.Uint32(buf[4:]) != runID {
+			continue
+		}
+
+		seq := binary.BigEndian.Uint32(buf[8:])
+		if seen[seq] {
+			continue
+		}
+
+		seen[seq] = true
+		sent := time.Unix(0, int64(binary.BigEndian.Uint64(buf[12:]))) //nolint:gosec // written by us
+
+		replies = append(replies, udpReply{seq: seq, rtt: received.Sub(sent)})
+	}
+
+	return replies
+}
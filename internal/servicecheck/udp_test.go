@@ -0,0 +1,123 @@
+package servicecheck
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestUDPCheck(t *testing.T) {
+	r := require.New(t)
+
+	checker, err := New(fake.NewFakeClient(), false, 3*time.Second, func(s string) Histogram {
+		return metrics.GetOrCreatePrometheusHistogram(s)
+	})
+	r.NoError(err)
+
+	checker.UDPProbes = 4
+	checker.UDPTimeout = 200 * time.Millisecond
+
+	listen := func() net.PacketConn {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		r.NoError(err)
+		t.Cleanup(func() { conn.Close() })
+
+		checker.UDPPort = conn.LocalAddr().(*net.UDPAddr).Port
+
+		return conn
+	}
+
+	check := func(requestType string) string {
+		ctx := context.WithValue(context.Background(), kubenurseTypeKey{}, requestType)
+		return checker.doUDPCheck(ctx, "127.0.0.1")
+	}
+
+	t.Run("echo", func(t *testing.T) {
+		conn := listen()
+		go func() { _ = ServeUDPEcho(conn) }()
+
+		require.Equal(t, okStr, check("udp_echo"))
+	})
+
+	t.Run("only probes are echoed", func(t *testing.T) {
+		r := require.New(t)
+
+		conn := listen()
+		go func() { _ = ServeUDPEcho(conn) }()
+
+		client, err := net.Dial("udp", conn.LocalAddr().String())
+		r.NoError(err)
+
+		defer client.Close()
+
+		probe := append(slices.Clone(udpMagic), make([]byte, udpProbeSize-len(udpMagic))...)
+
+		for _, msg := range [][]byte{
+			make([]byte, udpProbeSize),                         // without the magic
+			append(slices.Clone(probe), make([]byte, 1000)...), // too long
+			probe[:udpProbeSize-1],                             // too short
+			probe,
+		} {
+			_, err := client.Write(msg)
+			r.NoError(err)
+		}
+
+		r.NoError(client.SetReadDeadline(time.Now().Add(time.Second)))
+
+		buf := make([]byte, 2048)
+
+		n, err := client.Read(buf)
+		r.NoError(err)
+		r.Equal(probe, buf[:n], "the first echo must be the probe")
+
+		r.NoError(client.SetReadDeadline(time.Now().Add(50 * time.Millisecond)))
+
+		_, err = client.Read(buf)
+		r.Error(err, "nothing else must be echoed")
+	})
+
+	t.Run("loss", func(t *testing.T) {
+		conn := listen()
+
+		go func() { // only echo the datagrams with an even sequence number
+			buf := make([]byte, 1500)
+
+			for {
+				n, addr, err := conn.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+
+				if binary.BigEndian.Uint32(buf[8:])%2 == 0 {
+					_, _ = conn.WriteTo(buf[:n], addr)
+				}
+			}
+		}()
+
+		require.Equal(t, okStr, check("udp_loss"))
+	})
+
+	t.Run("no echo", func(t *testing.T) {
+		conn := listen()
+		r.NoError(conn.Close())
+
+		require.NotEqual(t, okStr, check("udp_closed"))
+	})
+
+	var sb strings.Builder
+
+	metrics.WritePrometheus(&sb, false)
+	r.Contains(sb.String(), `kubenurse_udp_loss_percent{type="udp_echo"} 0`)
+	r.Contains(sb.String(), `kubenurse_udp_rtt_seconds_count{type="udp_echo"} 4`)
+	r.Contains(sb.String(), `kubenurse_udp_loss_percent{type="udp_loss"} 50`)
+	r.Contains(sb.String(), `kubenurse_udp_loss_percent{type="udp_closed"} 100`)
+	r.Contains(sb.String(), `kubenurse_errors_total{type="udp_closed",event="no_reply"} 1`)
+}
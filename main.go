@@ -4,13 +4,25 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/postfinance/kubenurse/api/v1alpha1"
+	"github.com/postfinance/kubenurse/internal/config"
 	"github.com/postfinance/kubenurse/internal/kubenurse"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 	controllerruntime "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
@@ -26,26 +38,162 @@ func main() {
 
 	controllerruntime.SetLogger(klog.Background())
 
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		code := check(ctx, os.Args[2:])
+
+		cancel()
+		os.Exit(code)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "aggregate" {
+		aggregate(ctx, cancel)
+		return
+	}
+
 	slog.Info("kubenurse starting", "version", version)
-	restConf, err := controllerruntime.GetConfig()
+
+	cfg, err := config.Load(os.Getenv(config.FileEnv))
+	if err != nil {
+		slog.Error("error while loading the configuration", "err", err)
+		return
+	}
+
+	var server *kubenurse.Server
+
+	if cfg.Discovery.Standalone() {
+		slog.Info("running in standalone mode, without the Kubernetes API", "discovery", cfg.Discovery.Mode)
+
+		server, err = kubenurse.New(nil, cfg)
+	} else {
+		server, err = newKubernetesServer(ctx, cancel, cfg)
+	}
+
+	if err != nil {
+		slog.Error("error while creating the kubenurse server", "err", err)
+		return
+	}
+
+	go func() {
+		<-ctx.Done() // blocks until ctx is canceled
+
+		slog.Info("shutting down, received signal to stop")
+
+		if err := server.Shutdown(); err != nil {
+			slog.Error("error during graceful shutdown", "err", err)
+		}
+	}()
+
+	// blocks, until the server is stopped by calling Shutdown()
+	if err := server.Run(ctx); err != nil {
+		slog.Error("error while running kubenurse", "err", err)
+	}
+}
+
+// aggregate implements the kubenurse aggregate subcommand, which collects the
+// results of all the kubenurses instead of running the checks.
+func aggregate(ctx context.Context, cancel context.CancelFunc) {
+	slog.Info("kubenurse aggregator starting", "version", version)
+
+	cfg, err := config.Load(os.Getenv(config.FileEnv))
 	if err != nil {
-		slog.Error("error during controllerruntime.GetConfig()", "err", err)
+		slog.Error("error while loading the configuration", "err", err)
 		return
 	}
 
-	kubenurseNs := os.Getenv("KUBENURSE_NAMESPACE")
+	var c client.Client
+
+	if !cfg.Discovery.Standalone() {
+		if c, _, err = newCachedClient(ctx, cancel, cfg); err != nil {
+			slog.Error("error while creating the kubernetes client", "err", err)
+			return
+		}
+	}
+
+	aggregator, err := kubenurse.NewAggregator(c, cfg)
+	if err != nil {
+		slog.Error("error while creating the kubenurse aggregator", "err", err)
+		return
+	}
+
+	go func() {
+		<-ctx.Done() // blocks until ctx is canceled
+
+		slog.Info("shutting down, received signal to stop")
+
+		if err := aggregator.Shutdown(); err != nil {
+			slog.Error("error during graceful shutdown", "err", err)
+		}
+	}()
+
+	if err := aggregator.Run(ctx); err != nil {
+		slog.Error("error while running the kubenurse aggregator", "err", err)
+	}
+}
+
+// newKubernetesServer creates the kubenurse server with a cached client of
+// the Kubernetes API, which is stopped by cancel on errors.
+func newKubernetesServer(ctx context.Context, cancel context.CancelFunc, cfg *config.Config) (*kubenurse.Server, error) {
+	c, restConf, err := newCachedClient(ctx, cancel, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := kubenurse.New(c, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Events.Enabled {
+		clientset, err := kubernetes.NewForConfig(restConf)
+		if err != nil {
+			return nil, fmt.Errorf("create the kubernetes clientset: %w", err)
+		}
+
+		// the broadcaster deduplicates and rate limits the events
+		broadcaster := record.NewBroadcaster(record.WithContext(ctx))
+		broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+
+		server.UseEventRecorder(broadcaster.NewRecorder(c.Scheme(), corev1.EventSource{Component: "kubenurse"}))
+	}
+
+	server.StartNodeReadinessWatcher(ctx, c)
+
+	return server, nil
+}
+
+// newCachedClient creates a client of the Kubernetes API which reads the
+// objects from a cache, which is stopped by cancel on errors.
+func newCachedClient(ctx context.Context, cancel context.CancelFunc, cfg *config.Config) (client.Client, *rest.Config, error) {
+	restConf, err := controllerruntime.GetConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("get the kubernetes config: %w", err)
+	}
+
+	scheme, err := newScheme()
+	if err != nil {
+		return nil, nil, fmt.Errorf("register the types: %w", err)
+	}
+
+	endpointSliceNamespaces := map[string]cache.Config{
+		cfg.Checks.DNS.Namespace: {},
+	}
+
+	if cfg.Discovery.Mode == config.DiscoveryEndpointSlices {
+		endpointSliceNamespaces[cfg.Checker.Namespace] = cache.Config{}
+	}
 
 	ca, err := cache.New(restConf, cache.Options{
+		Scheme: scheme,
 		ByObject: map[client.Object]cache.ByObject{
 			&corev1.Pod{}: {Namespaces: map[string]cache.Config{
-				kubenurseNs: {},
+				cfg.Checker.Namespace: {},
 			}},
-			&corev1.Node{}: {},
+			&corev1.Node{}:               {},
+			&discoveryv1.EndpointSlice{}: {Namespaces: endpointSliceNamespaces},
 		},
 	})
 	if err != nil {
-		slog.Error("error during cache creation", "err", err)
-		return
+		return nil, nil, fmt.Errorf("create the cache: %w", err)
 	}
 
 	go func() {
@@ -56,6 +204,7 @@ func main() {
 	}()
 
 	opts := client.Options{
+		Scheme: scheme,
 		Cache: &client.CacheOptions{
 			Reader: ca,
 		},
@@ -63,30 +212,108 @@ func main() {
 
 	c, err := client.New(restConf, opts)
 	if err != nil {
-		slog.Error("error while starting controller-runtime client", "err", err)
-		return
+		return nil, nil, fmt.Errorf("create the controller-runtime client: %w", err)
 	}
 
-	server, err := kubenurse.New(c)
-	if err != nil {
-		slog.Error("error in kubenurse.New call", "err", err)
-		return
+	return c, restConf, nil
+}
+
+// check implements the kubenurse check subcommand, which runs the checks once
+// or several times, prints a report and returns the exit code: 0 if all the
+// checks succeeded, 1 if any of them failed, and 2 on any other error.
+func check(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("kubenurse check", flag.ContinueOnError)
+	runs := flags.Int("runs", 1, "number of times the checks are run")
+	interval := flags.Duration("interval", 5*time.Second, "pause between two runs")
+	output := flags.String("output", "text", "format of the report, text or json")
+	timeout := flags.Duration("timeout", time.Minute, "timeout of all the runs")
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: kubenurse check [flags]\n\n"+
+			"Runs the checks configured by the environment and KUBENURSE_CONFIG_FILE, and\n"+
+			"exits with 1 if any check failed.\n\n")
+		flags.PrintDefaults()
 	}
 
-	server.StartNodeReadinessWatcher(ctx, c)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
 
-	go func() {
-		<-ctx.Done() // blocks until ctx is canceled
+		return 2
+	}
 
-		slog.Info("shutting down, received signal to stop")
+	if *output != "text" && *output != "json" {
+		slog.Error("the output must be text or json", "output", *output)
+		return 2
+	}
 
-		if err := server.Shutdown(); err != nil {
-			slog.Error("error during graceful shutdown", "err", err)
+	cfg, err := config.Load(os.Getenv(config.FileEnv))
+	if err != nil {
+		slog.Error("error while loading the configuration", "err", err)
+		return 2
+	}
+
+	var c client.Client
+
+	if !cfg.Discovery.Standalone() {
+		if c, err = newDirectClient(); err != nil {
+			slog.Error("error while creating the kubernetes client", "err", err)
+			return 2
 		}
-	}()
+	}
 
-	// blocks, until the server is stopped by calling Shutdown()
-	if err := server.Run(ctx); err != nil {
-		slog.Error("error while running kubenurse", "err", err)
+	chk, err := kubenurse.NewChecker(c, cfg)
+	if err != nil {
+		slog.Error("error in kubenurse.NewChecker call", "err", err)
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	ok, err := kubenurse.RunChecks(ctx, chk, kubenurse.CheckOptions{
+		Runs:     *runs,
+		Interval: *interval,
+		JSON:     *output == "json",
+	}, os.Stdout)
+	if err != nil {
+		slog.Error("error while running the checks", "err", err)
+		return 2
+	}
+
+	if !ok {
+		return 1
 	}
+
+	return 0
+}
+
+// newDirectClient creates a client which reads the objects directly from the
+// API server, instead of a cache, as the checks are only run a few times.
+func newDirectClient() (client.Client, error) {
+	restConf, err := controllerruntime.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("get the kubernetes config: %w", err)
+	}
+
+	scheme, err := newScheme()
+	if err != nil {
+		return nil, fmt.Errorf("register the types: %w", err)
+	}
+
+	return client.New(restConf, client.Options{Scheme: scheme})
+}
+
+func newScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+
+	return scheme, nil
 }